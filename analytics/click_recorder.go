@@ -0,0 +1,252 @@
+package analytics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+const (
+	clickQueueSize = 1024
+	hourLayout     = "2006-01-02T15"
+	dayLayout      = "2006-01-02"
+)
+
+type ClickRecorderI interface {
+	RecordClick(click Click)
+	RetrieveStats(ctx context.Context, shortUrl string, hours, days int) (*Stats, error)
+	// Delete drops the statistics of a purged short url, so it doesn't pass
+	// them on to a short url created again with the same code.
+	Delete(ctx context.Context, shortUrl string) error
+	Close()
+}
+
+type Click struct {
+	ShortUrl  string
+	Referrer  string
+	UserAgent string
+	ClientIp  string
+	Time      time.Time
+}
+
+type Bucket struct {
+	Time   time.Time `json:"time"`
+	Clicks int64     `json:"clicks"`
+}
+
+type Stats struct {
+	ShortUrl       string           `json:"short_url"`
+	TotalClicks    int64            `json:"total_clicks"`
+	UniqueVisitors int64            `json:"unique_visitors"`
+	Hourly         []Bucket         `json:"hourly"`
+	Daily          []Bucket         `json:"daily"`
+	Referrers      map[string]int64 `json:"referrers"`
+	UserAgents     map[string]int64 `json:"user_agents"`
+}
+
+// RedisClickRecorder queues clicks and writes them to Redis from a background
+// worker, so recording never waits on Redis. Clicks are dropped when the
+// queue is full rather than slowing down the redirect.
+type RedisClickRecorder struct {
+	RedisClient redis.UniversalClient
+	keyPrefix   string
+	clicks      chan Click
+	done        sync.WaitGroup
+	closeOnce   sync.Once
+}
+
+func NewRedisClickRecorder(redisClient redis.UniversalClient, keyPrefix string) *RedisClickRecorder {
+	recorder := &RedisClickRecorder{
+		RedisClient: redisClient,
+		keyPrefix:   keyPrefix,
+		clicks:      make(chan Click, clickQueueSize),
+	}
+
+	recorder.done.Add(1)
+	go recorder.run()
+
+	return recorder
+}
+
+func (r *RedisClickRecorder) RecordClick(click Click) {
+	select {
+	case r.clicks <- click:
+	default:
+		log.Warn().Msg(fmt.Sprintf("Click queue is full, dropping click | shortUrl: %s", click.ShortUrl))
+	}
+}
+
+// Close stops accepting clicks and waits until the queued ones are written.
+func (r *RedisClickRecorder) Close() {
+	r.closeOnce.Do(func() {
+		close(r.clicks)
+	})
+	r.done.Wait()
+}
+
+func (r *RedisClickRecorder) run() {
+	defer r.done.Done()
+
+	for click := range r.clicks {
+		err := r.writeClick(context.Background(), click)
+		if err != nil {
+			log.Err(err).Msg(fmt.Sprintf("Failed recording click | Error: %v - shortUrl: %s", err, click.ShortUrl))
+		}
+	}
+}
+
+func (r *RedisClickRecorder) writeClick(ctx context.Context, click Click) error {
+	clickTime := click.Time.UTC()
+	hourlyKey := r.keyPrefix + store.HourlyStatsKey(click.ShortUrl, clickTime)
+	dailyKey := r.keyPrefix + store.DailyStatsKey(click.ShortUrl, clickTime)
+	nextMonth := truncateToMonth(clickTime).AddDate(0, 1, 0)
+
+	_, err := r.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Incr(ctx, r.keyPrefix+store.StatsKey(click.ShortUrl, "clicks"))
+		pipe.HIncrBy(ctx, hourlyKey, strconv.Itoa(clickTime.Hour()), 1)
+		pipe.ExpireAt(ctx, hourlyKey, truncateToDay(clickTime).AddDate(0, 0, 1).Add(store.StatsHourlyRetention))
+		pipe.HIncrBy(ctx, dailyKey, clickTime.Format(dayLayout), 1)
+		pipe.ExpireAt(ctx, dailyKey, nextMonth.Add(store.StatsDailyRetention))
+		pipe.HIncrBy(ctx, r.keyPrefix+store.StatsKey(click.ShortUrl, "referrers"), referrerHost(click.Referrer), 1)
+		pipe.HIncrBy(ctx, r.keyPrefix+store.StatsKey(click.ShortUrl, "user_agents"), UserAgentFamily(click.UserAgent), 1)
+		pipe.PFAdd(ctx, r.keyPrefix+store.StatsKey(click.ShortUrl, "visitors"), visitorId(click))
+		return nil
+	})
+	return err
+}
+
+func (r *RedisClickRecorder) RetrieveStats(ctx context.Context, shortUrl string, hours, days int) (*Stats, error) {
+	now := time.Now().UTC()
+	hourlyStart := now.Truncate(time.Hour).Add(-time.Duration(hours-1) * time.Hour)
+	dailyStart := truncateToDay(now).AddDate(0, 0, -(days - 1))
+
+	var hourlyKeys []string
+	for day := truncateToDay(hourlyStart); !day.After(now); day = day.AddDate(0, 0, 1) {
+		hourlyKeys = append(hourlyKeys, r.keyPrefix+store.HourlyStatsKey(shortUrl, day))
+	}
+	// The legacy hash goes first, so the monthly ones win for the same day.
+	dailyKeys := []string{r.keyPrefix + store.LegacyDailyStatsKey(shortUrl)}
+	for month := truncateToMonth(dailyStart); !month.After(now); month = month.AddDate(0, 1, 0) {
+		dailyKeys = append(dailyKeys, r.keyPrefix+store.DailyStatsKey(shortUrl, month))
+	}
+
+	var clicks *redis.StringCmd
+	var visitors *redis.IntCmd
+	var referrers, userAgents *redis.StringStringMapCmd
+	hourly := make([]*redis.StringStringMapCmd, len(hourlyKeys))
+	daily := make([]*redis.StringStringMapCmd, len(dailyKeys))
+
+	_, err := r.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		clicks = pipe.Get(ctx, r.keyPrefix+store.StatsKey(shortUrl, "clicks"))
+		visitors = pipe.PFCount(ctx, r.keyPrefix+store.StatsKey(shortUrl, "visitors"))
+		referrers = pipe.HGetAll(ctx, r.keyPrefix+store.StatsKey(shortUrl, "referrers"))
+		userAgents = pipe.HGetAll(ctx, r.keyPrefix+store.StatsKey(shortUrl, "user_agents"))
+		for i, key := range hourlyKeys {
+			hourly[i] = pipe.HGetAll(ctx, key)
+		}
+		for i, key := range dailyKeys {
+			daily[i] = pipe.HGetAll(ctx, key)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	totalClicks, err := clicks.Int64()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	stats := &Stats{
+		ShortUrl:       shortUrl,
+		TotalClicks:    totalClicks,
+		UniqueVisitors: visitors.Val(),
+		Referrers:      parseCounts(referrers.Val()),
+		UserAgents:     parseCounts(userAgents.Val()),
+	}
+
+	hourlyCounts := map[string]int64{}
+	for i, key := range hourlyKeys {
+		day := truncateToDay(hourlyStart).AddDate(0, 0, i)
+		for hour, count := range parseCounts(hourly[i].Val()) {
+			h, err := strconv.Atoi(hour)
+			if err != nil {
+				log.Warn().Msg(fmt.Sprintf("Skipping malformed hourly bucket | key: %s - field: %s", key, hour))
+				continue
+			}
+			hourlyCounts[day.Add(time.Duration(h)*time.Hour).Format(hourLayout)] = count
+		}
+	}
+	for bucket := hourlyStart; !bucket.After(now); bucket = bucket.Add(time.Hour) {
+		stats.Hourly = append(stats.Hourly, Bucket{Time: bucket, Clicks: hourlyCounts[bucket.Format(hourLayout)]})
+	}
+
+	dailyCounts := map[string]int64{}
+	for _, cmd := range daily {
+		for day, count := range parseCounts(cmd.Val()) {
+			dailyCounts[day] = count
+		}
+	}
+	for bucket := dailyStart; !bucket.After(now); bucket = bucket.AddDate(0, 0, 1) {
+		stats.Daily = append(stats.Daily, Bucket{Time: bucket, Clicks: dailyCounts[bucket.Format(dayLayout)]})
+	}
+
+	return stats, nil
+}
+
+func (r *RedisClickRecorder) Delete(ctx context.Context, shortUrl string) error {
+	keys := store.StatsKeys(shortUrl, time.Now())
+	for i, key := range keys {
+		keys[i] = r.keyPrefix + key
+	}
+	return r.RedisClient.Del(ctx, keys...).Err()
+}
+
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func truncateToMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func parseCounts(values map[string]string) map[string]int64 {
+	counts := make(map[string]int64, len(values))
+	for field, value := range values {
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		counts[field] = count
+	}
+	return counts
+}
+
+func referrerHost(referrer string) string {
+	if referrer == "" {
+		return "direct"
+	}
+
+	parsed, err := url.Parse(referrer)
+	if err != nil || parsed.Hostname() == "" {
+		return "unknown"
+	}
+	return parsed.Hostname()
+}
+
+// visitorId is only used for the unique visitor estimation, so the client ip
+// is hashed instead of being stored as is.
+func visitorId(click Click) string {
+	sum := sha256.Sum256([]byte(click.ClientIp + "|" + click.UserAgent))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,165 @@
+package analytics_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/analytics"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+const ShortUrl = "NpHftVNe"
+
+func TestRecordClickSuccess(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	recorder := analytics.NewRedisClickRecorder(redisClient, "")
+	now := time.Now()
+	recorder.RecordClick(analytics.Click{
+		ShortUrl:  ShortUrl,
+		Referrer:  "https://twitter.com/some/status",
+		UserAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/106.0.0.0 Safari/537.36",
+		ClientIp:  "10.0.0.1",
+		Time:      now,
+	})
+	recorder.RecordClick(analytics.Click{
+		ShortUrl:  ShortUrl,
+		UserAgent: "curl/7.85.0",
+		ClientIp:  "10.0.0.2",
+		Time:      now,
+	})
+	recorder.RecordClick(analytics.Click{
+		ShortUrl:  ShortUrl,
+		UserAgent: "curl/7.85.0",
+		ClientIp:  "10.0.0.2",
+		Time:      now,
+	})
+	recorder.Close()
+
+	stats, err := recorder.RetrieveStats(ctx, ShortUrl, 24, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), stats.TotalClicks)
+	assert.Equal(t, int64(2), stats.UniqueVisitors)
+	assert.Equal(t, map[string]int64{"twitter.com": 1, "direct": 2}, stats.Referrers)
+	assert.Equal(t, map[string]int64{"chrome": 1, "curl": 2}, stats.UserAgents)
+
+	assert.Len(t, stats.Hourly, 24)
+	assert.Equal(t, int64(3), stats.Hourly[23].Clicks)
+	assert.Len(t, stats.Daily, 7)
+	assert.Equal(t, int64(3), stats.Daily[6].Clicks)
+	assert.Equal(t, int64(0), stats.Daily[0].Clicks)
+}
+
+func TestRetrieveStatsWithoutClicks(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	recorder := analytics.NewRedisClickRecorder(redisClient, "")
+	defer recorder.Close()
+
+	stats, err := recorder.RetrieveStats(ctx, ShortUrl, 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), stats.TotalClicks)
+	assert.Len(t, stats.Hourly, 1)
+	assert.Len(t, stats.Daily, 1)
+}
+
+func TestRetrieveStatsRedisFail(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	recorder := analytics.NewRedisClickRecorder(redisClient, "")
+	defer recorder.Close()
+
+	redisServer.SetError("REDISDOWN")
+	stats, err := recorder.RetrieveStats(ctx, ShortUrl, 24, 7)
+	assert.Nil(t, stats)
+	assert.Error(t, err)
+}
+
+func TestRecordClickUnderKeyPrefix(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+
+	recorder := analytics.NewRedisClickRecorder(redisClient, "staging:")
+	recorder.RecordClick(analytics.Click{
+		ShortUrl:  ShortUrl,
+		UserAgent: "curl/7.85.0",
+		ClientIp:  "10.0.0.2",
+		Time:      time.Now(),
+	})
+	recorder.Close()
+
+	for _, key := range redisServer.Keys() {
+		assert.Regexp(t, "^staging:stats:", key)
+	}
+	stats, err := recorder.RetrieveStats(context.TODO(), ShortUrl, 24, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.TotalClicks)
+}
+
+func TestRecordClickExpiresTimeBuckets(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	now := time.Now().UTC()
+	yesterday := now.AddDate(0, 0, -1)
+	redisServer.HSet(store.LegacyDailyStatsKey(ShortUrl), yesterday.Format("2006-01-02"), "5")
+
+	recorder := analytics.NewRedisClickRecorder(redisClient, "")
+	recorder.RecordClick(analytics.Click{
+		ShortUrl:  ShortUrl,
+		UserAgent: "curl/7.85.0",
+		ClientIp:  "10.0.0.2",
+		Time:      now,
+	})
+	recorder.Close()
+
+	assert.Greater(t, redisServer.TTL(store.HourlyStatsKey(ShortUrl, now)), store.StatsHourlyRetention)
+	assert.Greater(t, redisServer.TTL(store.DailyStatsKey(ShortUrl, now)), store.StatsDailyRetention)
+	assert.Equal(t, time.Duration(0), redisServer.TTL(store.StatsKey(ShortUrl, "clicks")))
+
+	stats, err := recorder.RetrieveStats(ctx, ShortUrl, 24, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), stats.Daily[5].Clicks)
+	assert.Equal(t, int64(1), stats.Daily[6].Clicks)
+}
+
+func TestDeleteStats(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+
+	recorder := analytics.NewRedisClickRecorder(redisClient, "staging:")
+	recorder.RecordClick(analytics.Click{
+		ShortUrl:  ShortUrl,
+		UserAgent: "curl/7.85.0",
+		ClientIp:  "10.0.0.2",
+		Time:      time.Now(),
+	})
+	recorder.Close()
+	assert.NotEmpty(t, redisServer.Keys())
+
+	assert.NoError(t, recorder.Delete(context.TODO(), ShortUrl))
+	assert.Empty(t, redisServer.Keys())
+}
@@ -0,0 +1,114 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+const (
+	defaultStatsHours = 24
+	maxStatsHours     = 7 * 24
+	defaultStatsDays  = 30
+	maxStatsDays      = 365
+)
+
+type HandlerI interface {
+	GetStats(c *gin.Context)
+}
+
+type handler struct {
+	recorder ClickRecorderI
+	store    store.StorageServiceI
+}
+
+func NewHandler(recorder ClickRecorderI, store store.StorageServiceI) HandlerI {
+	return &handler{
+		recorder: recorder,
+		store:    store,
+	}
+}
+
+// TrackClicks records a click for every request on the route that ended in a
+// redirect. It goes after the redirect handler has run, so failed lookups are
+// not counted.
+func TrackClicks(recorder ClickRecorderI) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < http.StatusMultipleChoices || status >= http.StatusBadRequest {
+			return
+		}
+
+		recorder.RecordClick(Click{
+			ShortUrl:  c.Param("shortUrl"),
+			Referrer:  c.Request.Referer(),
+			UserAgent: c.Request.UserAgent(),
+			ClientIp:  c.ClientIP(),
+			Time:      time.Now(),
+		})
+	}
+}
+
+// GetStats is only shown to the owner of the short url, like GetLink.
+func (h *handler) GetStats(c *gin.Context) {
+	shortUrl := c.Param("shortUrl")
+
+	hours, err := boundedQueryInt(c, "hours", defaultStatsHours, maxStatsHours)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	days, err := boundedQueryInt(c, "days", defaultStatsDays, maxStatsDays)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	mapping, err := h.store.RetrieveUrlMapping(c, shortUrl)
+	if errors.Is(err, store.ErrUrlNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Short url doesn't exist!"})
+		return
+	}
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed retrieving url mapping | Error: %v - shortUrl: %s", err, shortUrl))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if principal, ok := auth.Principal(c); ok && !mapping.IsOwnedBy(principal) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the owner of this short url!"})
+		return
+	}
+
+	stats, err := h.recorder.RetrieveStats(c, shortUrl, hours, days)
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed retrieving stats | Error: %v - shortUrl: %s", err, shortUrl))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+func boundedQueryInt(c *gin.Context, name string, defaultValue, maxValue int) (int, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 1 || value > maxValue {
+		return 0, fmt.Errorf("Please input %s between 1 and %d!", name, maxValue)
+	}
+	return value, nil
+}
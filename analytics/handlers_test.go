@@ -0,0 +1,155 @@
+package analytics_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/analytics"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func TestGetStatsSuccess(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+	redisClient.Set(ctx, ShortUrl, "https://www.youtube.com/watch?v=dQw4w9WgXcQ", 0)
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	recorder := analytics.NewRedisClickRecorder(redisClient, "")
+	defer recorder.Close()
+	h := analytics.NewHandler(recorder, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+		URL:    &url.URL{RawQuery: "hours=12&days=3"},
+	}
+	c.AddParam("shortUrl", ShortUrl)
+
+	h.GetStats(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total_clicks":0`)
+}
+
+func TestGetStatsInvalidHours(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	recorder := analytics.NewRedisClickRecorder(redisClient, "")
+	defer recorder.Close()
+	h := analytics.NewHandler(recorder, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+		URL:    &url.URL{RawQuery: "hours=1000"},
+	}
+	c.AddParam("shortUrl", ShortUrl)
+
+	h.GetStats(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetStatsUnavailableShortLink(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	recorder := analytics.NewRedisClickRecorder(redisClient, "")
+	defer recorder.Close()
+	h := analytics.NewHandler(recorder, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+		URL:    &url.URL{},
+	}
+	c.AddParam("shortUrl", ShortUrl)
+
+	h.GetStats(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetStatsOfAnotherOwner(t *testing.T) {
+	ctx := context.TODO()
+	storageService := store.NewMemoryStorageService()
+	assert.NoError(t, storageService.CreateUrlMapping(ctx, ShortUrl, store.UrlMapping{
+		OriginalUrl: "https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+		Owner:       "alice",
+	}))
+	h := analytics.NewHandler(analytics.NewMemoryClickRecorder(), storageService)
+
+	for principal, status := range map[string]int{"alice": http.StatusOK, "bob": http.StatusForbidden} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = &http.Request{
+			Header: make(http.Header),
+			URL:    &url.URL{},
+		}
+		c.AddParam("shortUrl", ShortUrl)
+		auth.SetPrincipal(c, principal)
+
+		h.GetStats(c)
+
+		assert.Equal(t, status, w.Code, principal)
+	}
+}
+
+func TestTrackClicksOnlyCountsRedirects(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	recorder := analytics.NewRedisClickRecorder(redisClient, "")
+	router := gin.New()
+	router.GET("/:shortUrl", analytics.TrackClicks(recorder), func(c *gin.Context) {
+		if c.Param("shortUrl") != ShortUrl {
+			c.JSON(http.StatusNotFound, gin.H{})
+			return
+		}
+		c.Redirect(http.StatusFound, "https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	})
+
+	for _, path := range []string{"/" + ShortUrl, "/" + ShortUrl, "/unknown"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	recorder.Close()
+
+	stats, err := recorder.RetrieveStats(ctx, ShortUrl, 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.TotalClicks)
+
+	stats, err = recorder.RetrieveStats(ctx, "unknown", 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), stats.TotalClicks)
+}
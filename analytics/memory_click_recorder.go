@@ -0,0 +1,124 @@
+package analytics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+type memoryClickStats struct {
+	clicks     int64
+	visitors   map[string]struct{}
+	hourly     map[string]int64
+	daily      map[string]int64
+	referrers  map[string]int64
+	userAgents map[string]int64
+}
+
+// MemoryClickRecorder is the ClickRecorderI used with the in-memory storage
+// driver. It counts unique visitors exactly, which is fine at demo scale, and
+// drops the time buckets past the retention of the Redis ones.
+type MemoryClickRecorder struct {
+	mu    sync.Mutex
+	stats map[string]*memoryClickStats
+}
+
+func NewMemoryClickRecorder() *MemoryClickRecorder {
+	return &MemoryClickRecorder{
+		stats: map[string]*memoryClickStats{},
+	}
+}
+
+func (r *MemoryClickRecorder) RecordClick(click Click) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats, ok := r.stats[click.ShortUrl]
+	if !ok {
+		stats = &memoryClickStats{
+			visitors:   map[string]struct{}{},
+			hourly:     map[string]int64{},
+			daily:      map[string]int64{},
+			referrers:  map[string]int64{},
+			userAgents: map[string]int64{},
+		}
+		r.stats[click.ShortUrl] = stats
+	}
+
+	clickTime := click.Time.UTC()
+	hour, day := clickTime.Format(hourLayout), clickTime.Format(dayLayout)
+	if _, ok := stats.hourly[hour]; !ok {
+		pruneBuckets(stats.hourly, hourLayout, clickTime.Add(-store.StatsHourlyRetention))
+	}
+	if _, ok := stats.daily[day]; !ok {
+		pruneBuckets(stats.daily, dayLayout, clickTime.Add(-store.StatsDailyRetention))
+	}
+
+	stats.clicks++
+	stats.visitors[visitorId(click)] = struct{}{}
+	stats.hourly[hour]++
+	stats.daily[day]++
+	stats.referrers[referrerHost(click.Referrer)]++
+	stats.userAgents[UserAgentFamily(click.UserAgent)]++
+}
+
+func (r *MemoryClickRecorder) RetrieveStats(ctx context.Context, shortUrl string, hours, days int) (*Stats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := &Stats{
+		ShortUrl:   shortUrl,
+		Referrers:  map[string]int64{},
+		UserAgents: map[string]int64{},
+	}
+
+	stats, ok := r.stats[shortUrl]
+	if !ok {
+		stats = &memoryClickStats{}
+	}
+
+	result.TotalClicks = stats.clicks
+	result.UniqueVisitors = int64(len(stats.visitors))
+	for referrer, count := range stats.referrers {
+		result.Referrers[referrer] = count
+	}
+	for userAgent, count := range stats.userAgents {
+		result.UserAgents[userAgent] = count
+	}
+
+	now := time.Now().UTC()
+	hourlyStart := now.Truncate(time.Hour).Add(-time.Duration(hours-1) * time.Hour)
+	for bucket := hourlyStart; !bucket.After(now); bucket = bucket.Add(time.Hour) {
+		result.Hourly = append(result.Hourly, Bucket{Time: bucket, Clicks: stats.hourly[bucket.Format(hourLayout)]})
+	}
+
+	dailyStart := truncateToDay(now).AddDate(0, 0, -(days - 1))
+	for bucket := dailyStart; !bucket.After(now); bucket = bucket.AddDate(0, 0, 1) {
+		result.Daily = append(result.Daily, Bucket{Time: bucket, Clicks: stats.daily[bucket.Format(dayLayout)]})
+	}
+
+	return result, nil
+}
+
+func (r *MemoryClickRecorder) Delete(ctx context.Context, shortUrl string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.stats, shortUrl)
+	return nil
+}
+
+func (r *MemoryClickRecorder) Close() {}
+
+// pruneBuckets only runs when a new bucket is started, so at most once an
+// hour for the hourly ones.
+func pruneBuckets(buckets map[string]int64, layout string, before time.Time) {
+	for bucket := range buckets {
+		t, err := time.Parse(layout, bucket)
+		if err != nil || t.Before(before) {
+			delete(buckets, bucket)
+		}
+	}
+}
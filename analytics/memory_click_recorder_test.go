@@ -0,0 +1,61 @@
+package analytics_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/analytics"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func TestMemoryRecordClickSuccess(t *testing.T) {
+	recorder := analytics.NewMemoryClickRecorder()
+	defer recorder.Close()
+
+	now := time.Now()
+	recorder.RecordClick(analytics.Click{
+		ShortUrl:  ShortUrl,
+		Referrer:  "https://twitter.com/some/status",
+		UserAgent: "curl/7.85.0",
+		ClientIp:  "10.0.0.1",
+		Time:      now,
+	})
+	recorder.RecordClick(analytics.Click{
+		ShortUrl:  ShortUrl,
+		UserAgent: "curl/7.85.0",
+		ClientIp:  "10.0.0.1",
+		Time:      now,
+	})
+
+	stats, err := recorder.RetrieveStats(context.TODO(), ShortUrl, 24, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.TotalClicks)
+	assert.Equal(t, int64(1), stats.UniqueVisitors)
+	assert.Equal(t, map[string]int64{"twitter.com": 1, "direct": 1}, stats.Referrers)
+	assert.Equal(t, map[string]int64{"curl": 2}, stats.UserAgents)
+	assert.Equal(t, int64(2), stats.Hourly[23].Clicks)
+	assert.Equal(t, int64(2), stats.Daily[6].Clicks)
+}
+
+func TestMemoryStatsPurgedWithShortUrl(t *testing.T) {
+	ctx := context.TODO()
+	recorder := analytics.NewMemoryClickRecorder()
+	defer recorder.Close()
+	storage := store.NewMemoryStorageService()
+
+	removedAt := time.Now().Add(-2 * time.Hour)
+	assert.NoError(t, storage.CreateUrlMapping(ctx, ShortUrl, store.UrlMapping{OriginalUrl: "https://ultra.fandom.com/wiki/Ultraman_Cosmos", Owner: "alice", DeletedAt: &removedAt}))
+	recorder.RecordClick(analytics.Click{ShortUrl: ShortUrl, UserAgent: "curl/7.85.0", ClientIp: "10.0.0.1", Time: time.Now()})
+
+	purged, err := store.NewReaper(storage, time.Hour, time.Minute, store.WithPurgeListener(recorder.Delete)).Reap(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	assert.NoError(t, storage.CreateUrlMapping(ctx, ShortUrl, store.UrlMapping{OriginalUrl: "https://ultra.fandom.com/wiki/Ultraman_Dyna", Owner: "bob"}))
+	stats, err := recorder.RetrieveStats(ctx, ShortUrl, 24, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), stats.TotalClicks)
+	assert.Empty(t, stats.UserAgents)
+}
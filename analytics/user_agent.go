@@ -0,0 +1,35 @@
+package analytics
+
+import "strings"
+
+var userAgentFamilies = []struct {
+	family  string
+	markers []string
+}{
+	{"bot", []string{"bot", "crawler", "spider", "slurp", "facebookexternalhit"}},
+	{"curl", []string{"curl/"}},
+	{"edge", []string{"edg/", "edge/"}},
+	{"opera", []string{"opr/", "opera"}},
+	{"samsung internet", []string{"samsungbrowser/"}},
+	{"chrome", []string{"chrome/", "crios/"}},
+	{"firefox", []string{"firefox/", "fxios/"}},
+	{"safari", []string{"safari/"}},
+}
+
+// UserAgentFamily maps a User-Agent header to a coarse browser family. Order
+// matters because most browsers also claim to be Chrome and/or Safari.
+func UserAgentFamily(userAgent string) string {
+	if userAgent == "" {
+		return "unknown"
+	}
+
+	lowered := strings.ToLower(userAgent)
+	for _, candidate := range userAgentFamilies {
+		for _, marker := range candidate.markers {
+			if strings.Contains(lowered, marker) {
+				return candidate.family
+			}
+		}
+	}
+	return "other"
+}
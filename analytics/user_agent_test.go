@@ -0,0 +1,25 @@
+package analytics_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/analytics"
+)
+
+func TestUserAgentFamily(t *testing.T) {
+	userAgents := map[string]string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/106.0.0.0 Safari/537.36 Edg/106.0.1370.42":       "edge",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/106.0.0.0 Safari/537.36":                         "chrome",
+		"Mozilla/5.0 (X11; Linux x86_64; rv:105.0) Gecko/20100101 Firefox/105.0":                                                                  "firefox",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.0 Mobile/15E148 Safari/604.1": "safari",
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)":                                                                "bot",
+		"curl/7.85.0":  "curl",
+		"":             "unknown",
+		"HTTPie/3.2.1": "other",
+	}
+
+	for userAgent, family := range userAgents {
+		assert.Equal(t, family, analytics.UserAgentFamily(userAgent), userAgent)
+	}
+}
@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+// Actions of the audited management calls.
+const (
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionRemove   = "remove"
+	ActionRestore  = "restore"
+	ActionRollback = "rollback"
+
+	ActionBulkCreate = "bulk_create"
+)
+
+// Outcomes of an audited call, from its response status.
+const (
+	OutcomeSuccess  = "success"
+	OutcomeRejected = "rejected"
+	OutcomeError    = "error"
+)
+
+// Values of AUDIT_SINK. An empty sink turns auditing off.
+const (
+	FileDriver   = "file"
+	RedisDriver  = "redis"
+	MemoryDriver = "memory"
+)
+
+const (
+	DefaultQueryLimit = 100
+	MaxQueryLimit     = 1000
+)
+
+// Entry is one audited call. Before and After are the mapping as it was
+// before the call and as the call saved it, so they are empty for calls that
+// were rejected.
+type Entry struct {
+	Time      time.Time         `json:"time"`
+	Actor     string            `json:"actor,omitempty"`
+	ClientIp  string            `json:"client_ip"`
+	RequestId string            `json:"request_id"`
+	Action    string            `json:"action"`
+	ShortCode string            `json:"short_code,omitempty"`
+	Before    *store.UrlMapping `json:"before,omitempty"`
+	After     *store.UrlMapping `json:"after,omitempty"`
+	Outcome   string            `json:"outcome"`
+	Status    int               `json:"status"`
+}
+
+// Filter selects entries of an actor and of a short code in [From, To]. Zero
+// values don't filter.
+type Filter struct {
+	Actor     string
+	ShortCode string
+	From      time.Time
+	To        time.Time
+	Limit     int
+}
+
+// SinkI keeps the audit trail. Entries are only ever appended; Query returns
+// the newest matching entries first.
+type SinkI interface {
+	Write(ctx context.Context, entry Entry) error
+	Query(ctx context.Context, filter Filter) ([]Entry, error)
+}
+
+func (f Filter) limit() int {
+	if f.Limit <= 0 {
+		return DefaultQueryLimit
+	}
+	if f.Limit > MaxQueryLimit {
+		return MaxQueryLimit
+	}
+	return f.Limit
+}
+
+func (f Filter) matches(entry Entry) bool {
+	if f.Actor != "" && entry.Actor != f.Actor {
+		return false
+	}
+	if f.ShortCode != "" && entry.ShortCode != f.ShortCode {
+		return false
+	}
+	if !f.From.IsZero() && entry.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && entry.Time.After(f.To) {
+		return false
+	}
+	return true
+}
+
+func outcome(status int) string {
+	if status >= 500 {
+		return OutcomeError
+	}
+	if status >= 400 {
+		return OutcomeRejected
+	}
+	return OutcomeSuccess
+}
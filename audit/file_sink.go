@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+const (
+	DefaultFilePath = "audit.log"
+	maxFileLineSize = 1024 * 1024
+)
+
+// FileSink appends the entries to a file as JSON lines. A query reads the
+// whole file, so rotate it with a tool that doesn't truncate it in place.
+type FileSink struct {
+	path string
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink appends to the file at path, DefaultFilePath in the working
+// directory when empty.
+func NewFileSink(path string) (*FileSink, error) {
+	if path == "" {
+		path = DefaultFilePath
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{path: path, file: file}, nil
+}
+
+func (s *FileSink) Write(ctx context.Context, entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.file.Write(append(line, '\n'))
+	if err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *FileSink) Query(ctx context.Context, filter Filter) ([]Entry, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Only the newest limit matches are kept while reading.
+	limit := filter.limit()
+	var matched []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxFileLineSize)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		if !filter.matches(entry) {
+			continue
+		}
+		matched = append(matched, entry)
+		if len(matched) > limit {
+			matched = matched[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return newestFirst(matched), nil
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+func newestFirst(entries []Entry) []Entry {
+	reversed := make([]Entry, len(entries))
+	for i, entry := range entries {
+		reversed[len(entries)-1-i] = entry
+	}
+	return reversed
+}
@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type HandlerI interface {
+	GetAudit(c *gin.Context)
+}
+
+type handler struct {
+	sink SinkI
+}
+
+func NewHandler(sink SinkI) HandlerI {
+	return &handler{
+		sink: sink,
+	}
+}
+
+// GetAudit lists the newest entries, filtered by actor, short_code and a
+// from/to range in RFC 3339. To go further back, query again with to set to
+// the time of the oldest entry returned.
+func (h *handler) GetAudit(c *gin.Context) {
+	filter := Filter{
+		Actor:     c.Query("actor"),
+		ShortCode: c.Query("short_code"),
+		Limit:     DefaultQueryLimit,
+	}
+
+	var err error
+	if filter.From, err = queryTime(c, "from"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.To, err = queryTime(c, "to"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input a from before to!"})
+		return
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxQueryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Please input a limit between 1 and %d!", MaxQueryLimit)})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := h.sink.Query(c, filter)
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed querying audit entries | Error: %v - actor: %s - shortUrl: %s", err, filter.Actor, filter.ShortCode))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+	})
+}
+
+func queryTime(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Please input %s as an RFC 3339 time!", name)
+	}
+	return t, nil
+}
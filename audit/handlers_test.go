@@ -0,0 +1,69 @@
+package audit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/audit"
+)
+
+type AuditResponse struct {
+	Entries []audit.Entry `json:"entries"`
+}
+
+func MockGetAudit(t *testing.T, sink audit.SinkI, path string) (int, AuditResponse) {
+	router := gin.New()
+	router.GET("/admin/audit", audit.NewHandler(sink).GetAudit)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var response AuditResponse
+	if w.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	}
+	return w.Code, response
+}
+
+func TestGetAudit(t *testing.T) {
+	sink := audit.NewMemorySink()
+	base := time.Date(2022, 11, 9, 8, 0, 0, 0, time.UTC)
+	assert.NoError(t, sink.Write(context.TODO(), audit.Entry{Time: base, Actor: "e0dba740", Action: audit.ActionCreate, ShortCode: "cosmos"}))
+	assert.NoError(t, sink.Write(context.TODO(), audit.Entry{Time: base.Add(time.Hour), Actor: "e0dba740", Action: audit.ActionRemove, ShortCode: "cosmos"}))
+	assert.NoError(t, sink.Write(context.TODO(), audit.Entry{Time: base.Add(time.Hour), Actor: "someone-else", Action: audit.ActionCreate, ShortCode: "dyna"}))
+
+	code, response := MockGetAudit(t, sink, "/admin/audit?actor=e0dba740&short_code=cosmos&from=2022-11-09T08:30:00Z&to=2022-11-09T10:00:00Z")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, response.Entries, 1) {
+		assert.Equal(t, audit.ActionRemove, response.Entries[0].Action)
+	}
+
+	code, response = MockGetAudit(t, sink, "/admin/audit?limit=2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, response.Entries, 2)
+
+	code, response = MockGetAudit(t, sink, "/admin/audit?actor=nobody")
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotNil(t, response.Entries)
+	assert.Empty(t, response.Entries)
+}
+
+func TestGetAuditInvalidQuery(t *testing.T) {
+	sink := audit.NewMemorySink()
+
+	for _, path := range []string{
+		"/admin/audit?from=yesterday",
+		"/admin/audit?from=2022-11-10T00:00:00Z&to=2022-11-09T00:00:00Z",
+		"/admin/audit?limit=0",
+		"/admin/audit?limit=1001",
+	} {
+		code, _ := MockGetAudit(t, sink, path)
+		assert.Equal(t, http.StatusBadRequest, code, path)
+	}
+}
@@ -0,0 +1,39 @@
+package audit
+
+import (
+	"context"
+	"sync"
+)
+
+// MemorySink keeps the entries in the process memory, for tests and local
+// development. Everything is lost when the process stops.
+type MemorySink struct {
+	mu      sync.RWMutex
+	entries []Entry
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (s *MemorySink) Write(ctx context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *MemorySink) Query(ctx context.Context, filter Filter) ([]Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	limit := filter.limit()
+	entries := []Entry{}
+	for i := len(s.entries) - 1; i >= 0 && len(entries) < limit; i-- {
+		if filter.matches(s.entries[i]) {
+			entries = append(entries, s.entries[i])
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+const (
+	RequestIdHeader  = "X-Request-Id"
+	changeContextKey = "audit.change"
+	requestIdBytes   = 16
+)
+
+// Change is what the handler of an audited call knows about it. Fields left
+// empty are simply not recorded.
+type Change struct {
+	Actor     string
+	ShortCode string
+	Before    *store.UrlMapping
+	After     *store.UrlMapping
+}
+
+// Describe gives the change of the current call for the handler to fill in.
+// It is safe to use on routes that aren't audited.
+func Describe(c *gin.Context) *Change {
+	if value, ok := c.Get(changeContextKey); ok {
+		return value.(*Change)
+	}
+	change := &Change{}
+	c.Set(changeContextKey, change)
+	return change
+}
+
+// Track writes an entry for every call on the route, whatever its outcome,
+// once the handler has run. The request id is taken from X-Request-Id or
+// generated, and sent back in the response either way. A failing sink is
+// logged and doesn't fail the call, which is done by then.
+func Track(sink SinkI, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if sink == nil {
+			c.Next()
+			return
+		}
+
+		requestId := c.GetHeader(RequestIdHeader)
+		if requestId == "" {
+			requestId = newRequestId()
+		}
+		c.Header(RequestIdHeader, requestId)
+
+		change := Describe(c)
+		c.Next()
+
+		entry := Entry{
+			Time:      time.Now().UTC(),
+			Actor:     change.Actor,
+			ClientIp:  c.ClientIP(),
+			RequestId: requestId,
+			Action:    action,
+			ShortCode: change.ShortCode,
+			Before:    change.Before,
+			After:     change.After,
+			Status:    c.Writer.Status(),
+		}
+		if principal, ok := auth.Principal(c); ok {
+			entry.Actor = principal
+		}
+		if entry.ShortCode == "" {
+			entry.ShortCode = c.Param("shortUrl")
+		}
+		entry.Outcome = outcome(entry.Status)
+
+		if err := sink.Write(c, entry); err != nil {
+			log.Err(err).Msg(fmt.Sprintf("Failed writing audit entry | Error: %v - action: %s - shortUrl: %s - requestId: %s", err, action, entry.ShortCode, requestId))
+		}
+	}
+}
+
+func newRequestId() string {
+	b := make([]byte, requestIdBytes)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,83 @@
+package audit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/audit"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func MockAuditedRouter(sink audit.SinkI, status int) *gin.Engine {
+	router := gin.New()
+	router.POST("/links/:shortUrl/restore", func(c *gin.Context) {
+		auth.SetPrincipal(c, "e0dba740")
+	}, audit.Track(sink, audit.ActionRestore), func(c *gin.Context) {
+		change := audit.Describe(c)
+		change.Actor = "from the body"
+		if status == http.StatusOK {
+			change.After = &store.UrlMapping{OriginalUrl: "https://ultra.fandom.com/wiki/Ultraman_Cosmos"}
+		}
+		c.JSON(status, gin.H{})
+	})
+	return router
+}
+
+func TestTrackRecordsEveryOutcome(t *testing.T) {
+	sink := audit.NewMemorySink()
+
+	for _, status := range []int{http.StatusOK, http.StatusForbidden, http.StatusInternalServerError} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/links/cosmos/restore", nil)
+		req.Header.Set(audit.RequestIdHeader, "req-42")
+		req.RemoteAddr = "10.0.0.7:51234"
+		MockAuditedRouter(sink, status).ServeHTTP(w, req)
+		assert.Equal(t, "req-42", w.Header().Get(audit.RequestIdHeader))
+	}
+
+	entries, err := sink.Query(context.TODO(), audit.Filter{})
+	assert.NoError(t, err)
+	if !assert.Len(t, entries, 3) {
+		return
+	}
+
+	assert.Equal(t, audit.OutcomeError, entries[0].Outcome)
+	assert.Equal(t, http.StatusInternalServerError, entries[0].Status)
+	assert.Equal(t, audit.OutcomeRejected, entries[1].Outcome)
+	assert.Nil(t, entries[1].After)
+
+	success := entries[2]
+	assert.Equal(t, audit.OutcomeSuccess, success.Outcome)
+	assert.Equal(t, "e0dba740", success.Actor)
+	assert.Equal(t, "10.0.0.7", success.ClientIp)
+	assert.Equal(t, "req-42", success.RequestId)
+	assert.Equal(t, audit.ActionRestore, success.Action)
+	assert.Equal(t, "cosmos", success.ShortCode)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Cosmos", success.After.OriginalUrl)
+	assert.False(t, success.Time.IsZero())
+}
+
+func TestTrackGeneratesRequestId(t *testing.T) {
+	sink := audit.NewMemorySink()
+	w := httptest.NewRecorder()
+	MockAuditedRouter(sink, http.StatusOK).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/links/cosmos/restore", nil))
+
+	entries, err := sink.Query(context.TODO(), audit.Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Len(t, w.Header().Get(audit.RequestIdHeader), 32)
+	assert.Equal(t, w.Header().Get(audit.RequestIdHeader), entries[0].RequestId)
+}
+
+func TestTrackWithoutSink(t *testing.T) {
+	w := httptest.NewRecorder()
+	MockAuditedRouter(nil, http.StatusOK).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/links/cosmos/restore", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(audit.RequestIdHeader))
+}
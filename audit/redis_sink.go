@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	auditStreamKey = "audit:log"
+	entryField     = "entry"
+	queryScanBatch = 500
+	maxQueryScan   = 50000
+
+	// maxClockSkew is how far the clocks of the service and Redis can be
+	// apart, the stream ids are given by Redis while entries are timed by
+	// the service.
+	maxClockSkew = time.Minute
+)
+
+// RedisSink appends the entries to a Redis stream, which is never trimmed.
+// Stream ids are in unix milliseconds, so a time range only reads about that
+// part of the stream; the actor and short code are filtered while reading,
+// looking at no more than maxQueryScan entries per query.
+type RedisSink struct {
+	RedisClient redis.UniversalClient
+	keyPrefix   string
+}
+
+func NewRedisSink(redisClient redis.UniversalClient, keyPrefix string) *RedisSink {
+	return &RedisSink{
+		RedisClient: redisClient,
+		keyPrefix:   keyPrefix,
+	}
+}
+
+func (s *RedisSink) Write(ctx context.Context, entry Entry) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return s.RedisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.keyPrefix + auditStreamKey,
+		Values: map[string]interface{}{entryField: string(value)},
+	}).Err()
+}
+
+func (s *RedisSink) Query(ctx context.Context, filter Filter) ([]Entry, error) {
+	start, end := "-", "+"
+	if !filter.From.IsZero() {
+		start = strconv.FormatInt(filter.From.Add(-maxClockSkew).UnixMilli(), 10)
+	}
+	if !filter.To.IsZero() {
+		end = strconv.FormatInt(filter.To.Add(maxClockSkew).UnixMilli(), 10)
+	}
+
+	limit := filter.limit()
+	entries := []Entry{}
+	for scanned := 0; scanned < maxQueryScan; {
+		messages, err := s.RedisClient.XRevRangeN(ctx, s.keyPrefix+auditStreamKey, end, start, queryScanBatch).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, message := range messages {
+			scanned++
+			value, _ := message.Values[entryField].(string)
+
+			var entry Entry
+			if err := json.Unmarshal([]byte(value), &entry); err != nil {
+				return nil, err
+			}
+			if !filter.matches(entry) {
+				continue
+			}
+			entries = append(entries, entry)
+			if len(entries) == limit {
+				return entries, nil
+			}
+		}
+
+		if len(messages) < queryScanBatch {
+			break
+		}
+		end = previousStreamId(messages[len(messages)-1].ID)
+		if end == "" {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// previousStreamId gives the id right before id, so the next page of an
+// XREVRANGE doesn't repeat the last entry. Empty when id is the first one
+// possible.
+func previousStreamId(id string) string {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	ms, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return ""
+	}
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return ""
+	}
+
+	if seq > 0 {
+		return strconv.FormatUint(ms, 10) + "-" + strconv.FormatUint(seq-1, 10)
+	}
+	if ms == 0 {
+		return ""
+	}
+	return strconv.FormatUint(ms-1, 10) + "-" + strconv.FormatUint(^uint64(0), 10)
+}
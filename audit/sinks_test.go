@@ -0,0 +1,101 @@
+package audit_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/audit"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func runSinkTests(t *testing.T, newSink func(t *testing.T) audit.SinkI) {
+	// Close to now, the Redis sink ranges on the time entries are written.
+	base := time.Now().UTC().Add(-time.Minute)
+	entries := []audit.Entry{
+		{Time: base, Actor: "e0dba740", Action: audit.ActionCreate, ShortCode: "cosmos", After: &store.UrlMapping{OriginalUrl: "https://ultra.fandom.com/wiki/Ultraman_Cosmos"}, Outcome: audit.OutcomeSuccess, Status: 200},
+		{Time: base.Add(10 * time.Second), Actor: "e0dba740", Action: audit.ActionUpdate, ShortCode: "dyna", Outcome: audit.OutcomeRejected, Status: 403},
+		{Time: base.Add(20 * time.Second), Actor: "someone-else", Action: audit.ActionRemove, ShortCode: "cosmos", Outcome: audit.OutcomeSuccess, Status: 200},
+	}
+
+	t.Run("Query", func(t *testing.T) {
+		sink := newSink(t)
+		ctx := context.TODO()
+		for _, entry := range entries {
+			assert.NoError(t, sink.Write(ctx, entry))
+		}
+
+		query := func(filter audit.Filter) []string {
+			found, err := sink.Query(ctx, filter)
+			assert.NoError(t, err)
+			actions := []string{}
+			for _, entry := range found {
+				actions = append(actions, entry.Action)
+			}
+			return actions
+		}
+
+		assert.Equal(t, []string{audit.ActionRemove, audit.ActionUpdate, audit.ActionCreate}, query(audit.Filter{}))
+		assert.Equal(t, []string{audit.ActionUpdate, audit.ActionCreate}, query(audit.Filter{Actor: "e0dba740"}))
+		assert.Equal(t, []string{audit.ActionRemove, audit.ActionCreate}, query(audit.Filter{ShortCode: "cosmos"}))
+		assert.Equal(t, []string{audit.ActionRemove}, query(audit.Filter{Limit: 1}))
+		assert.Equal(t, []string{audit.ActionUpdate}, query(audit.Filter{From: base.Add(5 * time.Second), To: base.Add(15 * time.Second)}))
+		assert.Equal(t, []string{}, query(audit.Filter{Actor: "nobody"}))
+
+		found, err := sink.Query(ctx, audit.Filter{ShortCode: "cosmos", Actor: "e0dba740"})
+		assert.NoError(t, err)
+		if assert.Len(t, found, 1) {
+			assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Cosmos", found[0].After.OriginalUrl)
+			assert.True(t, base.Equal(found[0].Time))
+			assert.Equal(t, 200, found[0].Status)
+		}
+	})
+}
+
+func TestMemorySink(t *testing.T) {
+	runSinkTests(t, func(t *testing.T) audit.SinkI {
+		return audit.NewMemorySink()
+	})
+}
+
+func TestFileSink(t *testing.T) {
+	runSinkTests(t, func(t *testing.T) audit.SinkI {
+		sink, err := audit.NewFileSink(filepath.Join(t.TempDir(), "audit.log"))
+		assert.NoError(t, err)
+		t.Cleanup(func() { sink.Close() })
+		return sink
+	})
+}
+
+func TestRedisSink(t *testing.T) {
+	runSinkTests(t, func(t *testing.T) audit.SinkI {
+		redisServer := miniredis.RunT(t)
+		redisClient := redis.NewClient(&redis.Options{
+			Addr: redisServer.Addr(),
+		})
+		return audit.NewRedisSink(redisClient, "urlblaster:")
+	})
+}
+
+func TestRedisSinkQueriesPastOneBatch(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	sink := audit.NewRedisSink(redisClient, "")
+	ctx := context.TODO()
+
+	assert.NoError(t, sink.Write(ctx, audit.Entry{Time: time.Now(), Actor: "e0dba740", Action: audit.ActionCreate}))
+	for i := 0; i < 600; i++ {
+		assert.NoError(t, sink.Write(ctx, audit.Entry{Time: time.Now(), Actor: "someone-else", Action: audit.ActionUpdate}))
+	}
+
+	found, err := sink.Query(ctx, audit.Filter{Actor: "e0dba740"})
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+	assert.True(t, redisServer.Exists("audit:log"))
+}
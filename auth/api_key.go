@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+const (
+	apiKeyPrefix      = "ubk_"
+	apiKeyIdBytes     = 8
+	apiKeySecretBytes = 32
+)
+
+var ErrInvalidCredentials = errors.New("Please provide valid credentials!")
+
+type AuthenticatorI interface {
+	Authenticate(ctx context.Context, token string) (string, error)
+}
+
+type apiKeyAuthenticator struct {
+	storage store.StorageServiceI
+}
+
+func NewApiKeyAuthenticator(storage store.StorageServiceI) AuthenticatorI {
+	return &apiKeyAuthenticator{
+		storage: storage,
+	}
+}
+
+func (a *apiKeyAuthenticator) Authenticate(ctx context.Context, token string) (string, error) {
+	id, ok := parseApiKeyId(token)
+	if !ok {
+		return "", ErrInvalidCredentials
+	}
+
+	apiKey, err := a.storage.RetrieveApiKey(ctx, id)
+	if errors.Is(err, store.ErrApiKeyNotFound) {
+		return "", ErrInvalidCredentials
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(HashApiKey(token)), []byte(apiKey.Hash)) != 1 {
+		return "", ErrInvalidCredentials
+	}
+	return apiKey.Principal, nil
+}
+
+// GenerateApiKey gives a new key of the form ubk_<id>_<secret>. The id is
+// what the key is stored and revoked by.
+func GenerateApiKey() (string, string, error) {
+	id, err := randomHex(apiKeyIdBytes)
+	if err != nil {
+		return "", "", err
+	}
+
+	secret, err := randomHex(apiKeySecretBytes)
+	if err != nil {
+		return "", "", err
+	}
+
+	return id, apiKeyPrefix + id + "_" + secret, nil
+}
+
+// HashApiKey needs no salt or stretching: the keys are random and long enough
+// that a fast hash can't be brute forced.
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func parseApiKeyId(key string) (string, bool) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return "", false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(key, apiKeyPrefix), "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", false
+	}
+	return parts[0], true
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,66 @@
+package auth_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func MockApiKey(t *testing.T, storage store.StorageServiceI, principal string) (string, string) {
+	id, key, err := auth.GenerateApiKey()
+	assert.NoError(t, err)
+
+	err = storage.SaveApiKey(context.TODO(), store.ApiKey{
+		Id:        id,
+		Hash:      auth.HashApiKey(key),
+		Principal: principal,
+		CreatedAt: time.Now(),
+	})
+	assert.NoError(t, err)
+	return id, key
+}
+
+func TestGenerateApiKey(t *testing.T) {
+	id, key, err := auth.GenerateApiKey()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, "ubk_"+id+"_"))
+
+	otherId, otherKey, err := auth.GenerateApiKey()
+	assert.NoError(t, err)
+	assert.NotEqual(t, id, otherId)
+	assert.NotEqual(t, key, otherKey)
+	assert.NotEqual(t, auth.HashApiKey(key), auth.HashApiKey(otherKey))
+}
+
+func TestApiKeyAuthenticator(t *testing.T) {
+	storage := store.NewMemoryStorageService()
+	authenticator := auth.NewApiKeyAuthenticator(storage)
+	ctx := context.TODO()
+
+	id, key := MockApiKey(t, storage, "e0dba740-fc4b-4977-872c-d360239e6b10")
+
+	principal, err := authenticator.Authenticate(ctx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, "e0dba740-fc4b-4977-872c-d360239e6b10", principal)
+
+	invalid := []string{
+		"",
+		"hahaha",
+		key + "x",
+		"ubk_" + id + "_",
+		"ubk_unknown_" + strings.Repeat("a", 64),
+	}
+	for _, token := range invalid {
+		_, err = authenticator.Authenticate(ctx, token)
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials, token)
+	}
+
+	assert.NoError(t, storage.DeleteApiKey(ctx, id))
+	_, err = authenticator.Authenticate(ctx, key)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
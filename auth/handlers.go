@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+type HandlerI interface {
+	CreateApiKey(c *gin.Context)
+	RevokeApiKey(c *gin.Context)
+}
+
+type handler struct {
+	store store.StorageServiceI
+}
+
+type ApiKeyCreationRequest struct {
+	Principal string `json:"principal" binding:"required"`
+}
+
+type ApiKeyRevokeRequest struct {
+	Id string `json:"id" binding:"required"`
+}
+
+func NewHandler(store store.StorageServiceI) HandlerI {
+	return &handler{
+		store: store,
+	}
+}
+
+func (h *handler) CreateApiKey(c *gin.Context) {
+	var creationRequest ApiKeyCreationRequest
+	if err := c.ShouldBindJSON(&creationRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, key, err := GenerateApiKey()
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed generating api key | Error: %v", err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.store.SaveApiKey(c, store.ApiKey{
+		Id:        id,
+		Hash:      HashApiKey(key),
+		Principal: creationRequest.Principal,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed saving api key | Error: %v - id: %s - principal: %s", err, id, creationRequest.Principal))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message":   "api key created successfully",
+		"id":        id,
+		"api_key":   key,
+		"principal": creationRequest.Principal,
+	})
+}
+
+func (h *handler) RevokeApiKey(c *gin.Context) {
+	var revokeRequest ApiKeyRevokeRequest
+	if err := c.ShouldBindJSON(&revokeRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.store.DeleteApiKey(c, revokeRequest.Id)
+	if errors.Is(err, store.ErrApiKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Api key doesn't exist!"})
+		return
+	}
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed deleting api key | Error: %v - id: %s", err, revokeRequest.Id))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": "api key revoked successfully",
+	})
+}
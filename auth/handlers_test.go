@@ -0,0 +1,63 @@
+package auth_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func MockJSONPost(router *gin.Engine, path string, body interface{}) *httptest.ResponseRecorder {
+	jsonbytes, _ := json.Marshal(body)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(jsonbytes))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateAndRevokeApiKey(t *testing.T) {
+	storage := store.NewMemoryStorageService()
+	h := auth.NewHandler(storage)
+	router := gin.New()
+	router.POST("/admin/create-api-key", h.CreateApiKey)
+	router.POST("/admin/revoke-api-key", h.RevokeApiKey)
+
+	w := MockJSONPost(router, "/admin/create-api-key", auth.ApiKeyCreationRequest{Principal: "e0dba740-fc4b-4977-872c-d360239e6b10"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	apiKey, err := storage.RetrieveApiKey(context.TODO(), response["id"])
+	assert.NoError(t, err)
+	assert.Equal(t, "e0dba740-fc4b-4977-872c-d360239e6b10", apiKey.Principal)
+	assert.NotEqual(t, response["api_key"], apiKey.Hash)
+	assert.Equal(t, auth.HashApiKey(response["api_key"]), apiKey.Hash)
+
+	w = MockJSONPost(router, "/admin/revoke-api-key", auth.ApiKeyRevokeRequest{Id: response["id"]})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	_, err = auth.NewApiKeyAuthenticator(storage).Authenticate(context.TODO(), response["api_key"])
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	w = MockJSONPost(router, "/admin/revoke-api-key", auth.ApiKeyRevokeRequest{Id: response["id"]})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCreateApiKeyWithoutPrincipal(t *testing.T) {
+	h := auth.NewHandler(store.NewMemoryStorageService())
+	router := gin.New()
+	router.POST("/admin/create-api-key", h.CreateApiKey)
+
+	w := MockJSONPost(router, "/admin/create-api-key", auth.ApiKeyCreationRequest{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
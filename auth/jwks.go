@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	jwksFetchTimeout       = 5 * time.Second
+	jwksMinRefreshInterval = time.Minute
+)
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// jwks holds the public keys of the identity provider, read from a file or
+// fetched from an http(s) endpoint. Tokens signed with an unknown key id
+// trigger a reload, at most once a minute, to pick up rotated keys.
+type jwks struct {
+	source string
+	client *http.Client
+
+	mu         sync.RWMutex
+	keys       map[string]crypto.PublicKey
+	refreshed  time.Time
+	refreshing sync.Mutex
+}
+
+func newJwks(source string) (*jwks, error) {
+	j := &jwks{
+		source: source,
+		client: &http.Client{Timeout: jwksFetchTimeout},
+	}
+	if err := j.reload(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *jwks) key(kid string) (crypto.PublicKey, bool) {
+	if key, ok := j.lookup(kid); ok {
+		return key, true
+	}
+
+	j.refreshing.Lock()
+	defer j.refreshing.Unlock()
+
+	j.mu.RLock()
+	recentlyRefreshed := time.Since(j.refreshed) < jwksMinRefreshInterval
+	j.mu.RUnlock()
+	if recentlyRefreshed {
+		return j.lookup(kid)
+	}
+
+	if err := j.reload(); err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed reloading jwks | source: %s", j.source))
+	}
+	return j.lookup(kid)
+}
+
+// lookup falls back to the only key of the set for tokens without a key id.
+func (j *jwks) lookup(kid string) (crypto.PublicKey, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+func (j *jwks) reload() error {
+	content, err := j.read()
+
+	j.mu.Lock()
+	j.refreshed = time.Now()
+	j.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var set jsonWebKeySet
+	if err := json.Unmarshal(content, &set); err != nil {
+		return fmt.Errorf("parse jwks %s: %w", j.source, err)
+	}
+
+	// Identity providers publish keys of all kinds in the same set, the ones
+	// we can't verify with are skipped as long as one is left.
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.Warn().Msg(fmt.Sprintf("Skipping jwks key | Error: %v - source: %s - kid: %s", err, j.source, jwk.Kid))
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("jwks %s has no usable signing key", j.source)
+	}
+
+	j.mu.Lock()
+	j.keys = keys
+	j.mu.Unlock()
+	return nil
+}
+
+func (j *jwks) read() ([]byte, error) {
+	if !strings.HasPrefix(j.source, "http://") && !strings.HasPrefix(j.source, "https://") {
+		return os.ReadFile(j.source)
+	}
+
+	resp, err := j.client.Get(j.source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks %s: unexpected status %d", j.source, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("rsa exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"source.golabs.io/daniel.santoso/url-blaster/config"
+)
+
+const (
+	jwtAlgorithmHS256 = "HS256"
+	jwtAlgorithmRS256 = "RS256"
+	jwtAlgorithmES256 = "ES256"
+
+	defaultJwtLeeway = 30 * time.Second
+)
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Subject   string      `json:"sub"`
+	Issuer    string      `json:"iss"`
+	Audience  jwtAudience `json:"aud"`
+	ExpiresAt *int64      `json:"exp"`
+	NotBefore *int64      `json:"nbf"`
+}
+
+// jwtAudience is either a single string or a list of strings.
+type jwtAudience []string
+
+func (a *jwtAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = jwtAudience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+type jwtAuthenticator struct {
+	secret   []byte
+	jwks     *jwks
+	issuer   string
+	audience string
+	leeway   time.Duration
+}
+
+// NewJwtAuthenticator accepts tokens of our identity provider, signed with
+// JWT_HS256_SECRET or with one of the RS256/ES256 keys of JWT_JWKS_URL. The
+// sub claim becomes the principal.
+func NewJwtAuthenticator(cfg *config.Config) (AuthenticatorI, error) {
+	a := &jwtAuthenticator{
+		secret:   []byte(cfg.JwtHs256Secret),
+		issuer:   cfg.JwtIssuer,
+		audience: cfg.JwtAudience,
+		leeway:   time.Duration(cfg.JwtLeewaySeconds) * time.Second,
+	}
+	if cfg.JwtLeewaySeconds <= 0 {
+		a.leeway = defaultJwtLeeway
+	}
+
+	if cfg.JwtJwksUrl != "" {
+		keys, err := newJwks(cfg.JwtJwksUrl)
+		if err != nil {
+			return nil, err
+		}
+		a.jwks = keys
+	}
+
+	if len(a.secret) == 0 && a.jwks == nil {
+		return nil, fmt.Errorf("jwt authentication needs JWT_HS256_SECRET or JWT_JWKS_URL")
+	}
+	return a, nil
+}
+
+func (a *jwtAuthenticator) Authenticate(ctx context.Context, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidCredentials
+	}
+
+	var header jwtHeader
+	if err := decodeJwtSegment(parts[0], &header); err != nil {
+		return "", ErrInvalidCredentials
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrInvalidCredentials
+	}
+
+	if !a.verifySignature(header, parts[0]+"."+parts[1], signature) {
+		return "", fmt.Errorf("%w: invalid signature", ErrInvalidCredentials)
+	}
+
+	var claims jwtClaims
+	if err := decodeJwtSegment(parts[1], &claims); err != nil {
+		return "", ErrInvalidCredentials
+	}
+
+	if err := a.validateClaims(claims, time.Now()); err != nil {
+		return "", err
+	}
+	return claims.Subject, nil
+}
+
+// verifySignature only accepts the algorithms we have keys for, so a token
+// can't pick a weaker one, like HS256 signed with a public RSA key.
+func (a *jwtAuthenticator) verifySignature(header jwtHeader, signingInput string, signature []byte) bool {
+	digest := sha256.Sum256([]byte(signingInput))
+
+	switch header.Alg {
+	case jwtAlgorithmHS256:
+		if len(a.secret) == 0 {
+			return false
+		}
+		mac := hmac.New(sha256.New, a.secret)
+		mac.Write([]byte(signingInput))
+		return hmac.Equal(signature, mac.Sum(nil))
+	case jwtAlgorithmRS256:
+		key, ok := a.publicKey(header.Kid).(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	case jwtAlgorithmES256:
+		key, ok := a.publicKey(header.Kid).(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key, digest[:], r, s)
+	default:
+		return false
+	}
+}
+
+func (a *jwtAuthenticator) publicKey(kid string) crypto.PublicKey {
+	if a.jwks == nil {
+		return nil
+	}
+	key, _ := a.jwks.key(kid)
+	return key
+}
+
+func (a *jwtAuthenticator) validateClaims(claims jwtClaims, now time.Time) error {
+	if claims.Subject == "" {
+		return fmt.Errorf("%w: missing sub claim", ErrInvalidCredentials)
+	}
+
+	if claims.ExpiresAt == nil || now.After(time.Unix(*claims.ExpiresAt, 0).Add(a.leeway)) {
+		return fmt.Errorf("%w: token expired", ErrInvalidCredentials)
+	}
+	if claims.NotBefore != nil && now.Add(a.leeway).Before(time.Unix(*claims.NotBefore, 0)) {
+		return fmt.Errorf("%w: token not valid yet", ErrInvalidCredentials)
+	}
+
+	if a.issuer != "" && claims.Issuer != a.issuer {
+		return fmt.Errorf("%w: unexpected issuer", ErrInvalidCredentials)
+	}
+	if a.audience != "" && !containsString(claims.Audience, a.audience) {
+		return fmt.Errorf("%w: unexpected audience", ErrInvalidCredentials)
+	}
+	return nil
+}
+
+func decodeJwtSegment(segment string, v interface{}) error {
+	content, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, v)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+package auth_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
+	"source.golabs.io/daniel.santoso/url-blaster/config"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+const JwtSecret = "2f5c8b0e7d1a4c3b9e6f"
+
+func encodeJwtSegment(t *testing.T, v interface{}) string {
+	content, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+// MockJwt signs claims with key, which is a shared secret for HS256, an
+// *rsa.PrivateKey for RS256 or an *ecdsa.PrivateKey for ES256.
+func MockJwt(t *testing.T, alg string, kid string, key interface{}, claims map[string]interface{}) string {
+	signingInput := encodeJwtSegment(t, map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encodeJwtSegment(t, claims)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, []byte(key.(string)))
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case "RS256":
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+		assert.NoError(t, err)
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest[:])
+		assert.NoError(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func MockClaims(subject string) map[string]interface{} {
+	return map[string]interface{}{
+		"sub": subject,
+		"iss": "https://id.example.com",
+		"aud": []string{"url-blaster", "other"},
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iat": time.Now().Unix(),
+	}
+}
+
+func MockJwks(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) []byte {
+	encode := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.Bytes())
+	}
+
+	content, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa-1", "use": "sig", "alg": "RS256", "n": encode(rsaKey.N), "e": encode(big.NewInt(int64(rsaKey.E)))},
+			{"kty": "EC", "kid": "ec-1", "use": "sig", "alg": "ES256", "crv": "P-256", "x": encode(ecKey.X), "y": encode(ecKey.Y)},
+		},
+	})
+	assert.NoError(t, err)
+	return content
+}
+
+func TestJwtAuthenticatorHS256(t *testing.T) {
+	authenticator, err := auth.NewJwtAuthenticator(&config.Config{
+		JwtHs256Secret: JwtSecret,
+		JwtIssuer:      "https://id.example.com",
+		JwtAudience:    "url-blaster",
+	})
+	assert.NoError(t, err)
+	ctx := context.TODO()
+
+	principal, err := authenticator.Authenticate(ctx, MockJwt(t, "HS256", "", JwtSecret, MockClaims("e0dba740-fc4b-4977-872c-d360239e6b10")))
+	assert.NoError(t, err)
+	assert.Equal(t, "e0dba740-fc4b-4977-872c-d360239e6b10", principal)
+
+	claims := MockClaims("someone")
+	claims["aud"] = "url-blaster"
+	_, err = authenticator.Authenticate(ctx, MockJwt(t, "HS256", "", JwtSecret, claims))
+	assert.NoError(t, err)
+
+	invalid := map[string]func(map[string]interface{}){
+		"expired":      func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"no expiry":    func(c map[string]interface{}) { delete(c, "exp") },
+		"not before":   func(c map[string]interface{}) { c["nbf"] = time.Now().Add(time.Hour).Unix() },
+		"issuer":       func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" },
+		"audience":     func(c map[string]interface{}) { c["aud"] = "other" },
+		"subject":      func(c map[string]interface{}) { delete(c, "sub") },
+		"wrong secret": nil,
+	}
+	for name, mutate := range invalid {
+		claims := MockClaims("someone")
+		secret := JwtSecret
+		if mutate != nil {
+			mutate(claims)
+		} else {
+			secret = "another secret"
+		}
+
+		_, err = authenticator.Authenticate(ctx, MockJwt(t, "HS256", "", secret, claims))
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials, name)
+	}
+
+	_, err = authenticator.Authenticate(ctx, "not.a.jwt")
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	header := encodeJwtSegment(t, map[string]string{"alg": "none"})
+	_, err = authenticator.Authenticate(ctx, header+"."+encodeJwtSegment(t, MockClaims("someone"))+".")
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+func TestJwtAuthenticatorJwksFile(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(jwksFile, MockJwks(t, rsaKey, ecKey), 0o644))
+
+	authenticator, err := auth.NewJwtAuthenticator(&config.Config{
+		JwtJwksUrl:  jwksFile,
+		JwtAudience: "url-blaster",
+	})
+	assert.NoError(t, err)
+	ctx := context.TODO()
+
+	principal, err := authenticator.Authenticate(ctx, MockJwt(t, "RS256", "rsa-1", rsaKey, MockClaims("rsa-user")))
+	assert.NoError(t, err)
+	assert.Equal(t, "rsa-user", principal)
+
+	principal, err = authenticator.Authenticate(ctx, MockJwt(t, "ES256", "ec-1", ecKey, MockClaims("ec-user")))
+	assert.NoError(t, err)
+	assert.Equal(t, "ec-user", principal)
+
+	_, err = authenticator.Authenticate(ctx, MockJwt(t, "RS256", "ec-1", rsaKey, MockClaims("rsa-user")))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	_, err = authenticator.Authenticate(ctx, MockJwt(t, "ES256", "ec-1", otherKey, MockClaims("ec-user")))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	// Without a shared secret HS256 is refused, whatever it is signed with.
+	_, err = authenticator.Authenticate(ctx, MockJwt(t, "HS256", "", "", MockClaims("someone")))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+func TestJwtAuthenticatorJwksEndpoint(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(MockJwks(t, rsaKey, ecKey))
+	}))
+	defer server.Close()
+
+	authenticator, err := auth.NewJwtAuthenticator(&config.Config{JwtJwksUrl: server.URL})
+	assert.NoError(t, err)
+
+	principal, err := authenticator.Authenticate(context.TODO(), MockJwt(t, "RS256", "rsa-1", rsaKey, MockClaims("rsa-user")))
+	assert.NoError(t, err)
+	assert.Equal(t, "rsa-user", principal)
+}
+
+func TestJwtAuthenticatorNeedsKeys(t *testing.T) {
+	_, err := auth.NewJwtAuthenticator(&config.Config{})
+	assert.Error(t, err)
+
+	_, err = auth.NewJwtAuthenticator(&config.Config{JwtJwksUrl: filepath.Join(t.TempDir(), "missing.json")})
+	assert.Error(t, err)
+}
+
+func TestJwtAuthenticatorSkipsUnusableJwksKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	encode := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.Bytes())
+	}
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	writeJwks := func(keys ...map[string]string) {
+		content, err := json.Marshal(map[string]interface{}{"keys": keys})
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(jwksFile, content, 0o644))
+	}
+	edKey := map[string]string{"kty": "OKP", "kid": "ed-1", "use": "sig", "alg": "EdDSA", "crv": "Ed25519", "x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}
+	encKey := map[string]string{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": "", "e": "AQAB"}
+	brokenKey := map[string]string{"kty": "EC", "kid": "ec-1", "use": "sig", "crv": "P-256", "x": "!", "y": "!"}
+
+	writeJwks(edKey, encKey, brokenKey, map[string]string{"kty": "RSA", "kid": "rsa-1", "use": "sig", "alg": "RS256", "n": encode(rsaKey.N), "e": encode(big.NewInt(int64(rsaKey.E)))})
+	authenticator, err := auth.NewJwtAuthenticator(&config.Config{JwtJwksUrl: jwksFile})
+	assert.NoError(t, err)
+	principal, err := authenticator.Authenticate(context.TODO(), MockJwt(t, "RS256", "rsa-1", rsaKey, MockClaims("rsa-user")))
+	assert.NoError(t, err)
+	assert.Equal(t, "rsa-user", principal)
+
+	writeJwks(edKey, encKey, brokenKey)
+	_, err = auth.NewJwtAuthenticator(&config.Config{JwtJwksUrl: jwksFile})
+	assert.Error(t, err)
+}
+
+func TestRequireBearerWithJwt(t *testing.T) {
+	jwtAuthenticator, err := auth.NewJwtAuthenticator(&config.Config{JwtHs256Secret: JwtSecret})
+	assert.NoError(t, err)
+
+	router := gin.New()
+	router.POST("/create-short-url", auth.RequireBearer(auth.NewApiKeyAuthenticator(store.NewMemoryStorageService()), jwtAuthenticator), func(c *gin.Context) {
+		principal, _ := auth.Principal(c)
+		c.String(http.StatusOK, principal)
+	})
+
+	w := MockAuthenticatedRequest(router, "/create-short-url", MockJwt(t, "HS256", "", JwtSecret, MockClaims("jwt-user")))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "jwt-user", w.Body.String())
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// RequireBearer lets a request through when one of the authenticators accepts
+// its bearer token, and makes the principal available through Principal.
+func RequireBearer(authenticators ...AuthenticatorI) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := bearerToken(c)
+		if !ok {
+			abortUnauthorized(c)
+			return
+		}
+
+		var failure error
+		for _, authenticator := range authenticators {
+			principal, err := authenticator.Authenticate(c, token)
+			if err == nil {
+				SetPrincipal(c, principal)
+				c.Next()
+				return
+			}
+			if !errors.Is(err, ErrInvalidCredentials) {
+				failure = err
+			}
+		}
+
+		if failure != nil {
+			log.Err(failure).Msg(fmt.Sprintf("Failed authenticating request | Error: %v", failure))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": failure.Error()})
+			return
+		}
+		abortUnauthorized(c)
+	}
+}
+
+// RequireAdminToken guards the endpoints managing the api keys with the
+// static ADMIN_TOKEN.
+func RequireAdminToken(adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := bearerToken(c)
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			abortUnauthorized(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+func bearerToken(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func abortUnauthorized(c *gin.Context) {
+	c.Header("WWW-Authenticate", "Bearer")
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrInvalidCredentials.Error()})
+}
@@ -0,0 +1,61 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func MockAuthenticatedRequest(router *gin.Engine, path string, token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequireBearer(t *testing.T) {
+	storage := store.NewMemoryStorageService()
+	_, key := MockApiKey(t, storage, "e0dba740-fc4b-4977-872c-d360239e6b10")
+
+	router := gin.New()
+	router.POST("/create-short-url", auth.RequireBearer(auth.NewApiKeyAuthenticator(storage)), func(c *gin.Context) {
+		principal, _ := auth.Principal(c)
+		c.String(http.StatusOK, principal)
+	})
+
+	w := MockAuthenticatedRequest(router, "/create-short-url", key)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "e0dba740-fc4b-4977-872c-d360239e6b10", w.Body.String())
+
+	w = MockAuthenticatedRequest(router, "/create-short-url", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+
+	w = MockAuthenticatedRequest(router, "/create-short-url", "ubk_nope_nope")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/create-short-url", nil)
+	req.Header.Set("Authorization", "Basic "+key)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRequireAdminToken(t *testing.T) {
+	router := gin.New()
+	router.POST("/admin/create-api-key", auth.RequireAdminToken("s3cret"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	assert.Equal(t, http.StatusOK, MockAuthenticatedRequest(router, "/admin/create-api-key", "s3cret").Code)
+	assert.Equal(t, http.StatusUnauthorized, MockAuthenticatedRequest(router, "/admin/create-api-key", "s3cre").Code)
+	assert.Equal(t, http.StatusUnauthorized, MockAuthenticatedRequest(router, "/admin/create-api-key", "").Code)
+}
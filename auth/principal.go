@@ -0,0 +1,16 @@
+package auth
+
+import "github.com/gin-gonic/gin"
+
+const principalContextKey = "auth.principal"
+
+func SetPrincipal(c *gin.Context, principal string) {
+	c.Set(principalContextKey, principal)
+}
+
+// Principal gives who the request was authenticated as. It is not set when
+// authentication is disabled.
+func Principal(c *gin.Context) (string, bool) {
+	principal := c.GetString(principalContextKey)
+	return principal, principal != ""
+}
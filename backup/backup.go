@@ -0,0 +1,128 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+// Formats of an export.
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+// Conflict strategies of an import, for short urls already in use.
+const (
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictFail      = "fail"
+)
+
+var (
+	ErrUnknownFormat   = errors.New("unknown export format")
+	ErrUnknownConflict = errors.New("unknown conflict strategy")
+	ErrConflict        = errors.New("short url is already in use")
+	ErrInvalidRecord   = errors.New("invalid record")
+)
+
+// Record is one exported mapping.
+type Record struct {
+	ShortUrl string `json:"short_url"`
+	store.UrlMapping
+}
+
+// ImportReport counts the records of an import by what happened to them.
+type ImportReport struct {
+	Imported    int64 `json:"imported"`
+	Overwritten int64 `json:"overwritten"`
+	Skipped     int64 `json:"skipped"`
+}
+
+// Export writes every mapping of the storage, removed and expired ones still
+// kept included, and returns how many there were.
+func Export(ctx context.Context, storage store.StorageServiceI, writer RecordWriterI) (int64, error) {
+	var exported int64
+	err := storage.ScanUrlMappings(ctx, func(mapping store.ListedUrlMapping) error {
+		exported++
+		return writer.Write(Record{ShortUrl: mapping.ShortUrl, UrlMapping: mapping.UrlMapping})
+	})
+	if err != nil {
+		return exported, err
+	}
+	return exported, writer.Flush()
+}
+
+// Import creates the mappings read, with their metadata as exported. A short
+// url in use is skipped, overwritten or stops the import with ErrConflict,
+// as conflict says; an expired mapping is never in the way. The import also
+// stops at the first record that can't be read or lacks a short or original
+// url, with ErrInvalidRecord. Everything before the stop stays imported.
+func Import(ctx context.Context, storage store.StorageServiceI, reader RecordReaderI, conflict string) (ImportReport, error) {
+	var report ImportReport
+	if !IsValidConflict(conflict) {
+		return report, ErrUnknownConflict
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return report, nil
+		}
+		if err != nil {
+			return report, fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+		}
+		if err := validateRecord(record); err != nil {
+			return report, fmt.Errorf("%w: %s: %v", ErrInvalidRecord, reader.Position(), err)
+		}
+
+		err = storage.CreateUrlMapping(ctx, record.ShortUrl, record.UrlMapping)
+		if errors.Is(err, store.ErrShortUrlTaken) {
+			switch conflict {
+			case ConflictSkip:
+				report.Skipped++
+				continue
+			case ConflictFail:
+				return report, fmt.Errorf("%s: %w: %s", reader.Position(), ErrConflict, record.ShortUrl)
+			}
+
+			err = storage.SaveUrlMapping(ctx, record.ShortUrl, record.UrlMapping)
+			if err == nil {
+				report.Overwritten++
+				continue
+			}
+		}
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", reader.Position(), err)
+		}
+		report.Imported++
+	}
+}
+
+func IsValidFormat(format string) bool {
+	return format == FormatJSONL || format == FormatCSV
+}
+
+func IsValidConflict(conflict string) bool {
+	switch conflict {
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+		return true
+	}
+	return false
+}
+
+func validateRecord(record Record) error {
+	if record.ShortUrl == "" {
+		return errors.New("short_url is missing")
+	}
+	if !store.IsValidShortUrl(record.ShortUrl) {
+		return fmt.Errorf("short_url %s is reserved, a route of the service or holds /, { or }", record.ShortUrl)
+	}
+	if record.OriginalUrl == "" {
+		return fmt.Errorf("original_url of %s is missing", record.ShortUrl)
+	}
+	return nil
+}
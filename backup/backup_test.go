@@ -0,0 +1,149 @@
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/backup"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func MockRedisStorage(t *testing.T) *store.StorageService {
+	redisServer := miniredis.RunT(t)
+	return &store.StorageService{
+		RedisClient: redis.NewClient(&redis.Options{Addr: redisServer.Addr()}),
+		KeyPrefix:   "henshin:",
+	}
+}
+
+func MockSourceStorage(t *testing.T) store.StorageServiceI {
+	ctx := context.TODO()
+	createdAt := time.Date(2022, 11, 9, 8, 0, 0, 0, time.UTC)
+	expiresAt := createdAt.Add(24 * 365 * time.Hour * 10)
+	deletedAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	storage := store.NewMemoryStorageService()
+	assert.NoError(t, storage.CreateUrlMapping(ctx, "cosmos", store.UrlMapping{
+		OriginalUrl:     "https://ultra.fandom.com/wiki/Ultraman_Cosmos",
+		Owner:           "e0dba740",
+		ExpiresAt:       &expiresAt,
+		RedirectType:    301,
+		ForwardQuery:    true,
+		QueryPrecedence: store.QueryPrecedenceAppend,
+		Title:           "Ultraman Cosmos, \"the\" series",
+		Tags:            []string{"tokusatsu", "heisei, late"},
+		CreatedAt:       &createdAt,
+		UpdatedAt:       &createdAt,
+	}))
+	assert.NoError(t, storage.CreateUrlMapping(ctx, "dyna", store.UrlMapping{
+		OriginalUrl: "https://ultra.fandom.com/wiki/Ultraman_Dyna",
+		Owner:       "e0dba740",
+		DeletedAt:   &deletedAt,
+	}))
+	assert.NoError(t, storage.CreateUrlMapping(ctx, "legacy", store.UrlMapping{
+		OriginalUrl: "https://ultra.fandom.com/wiki/Ultraman",
+	}))
+	return storage
+}
+
+func TestExportAndImportBetweenStorages(t *testing.T) {
+	for _, format := range []string{backup.FormatJSONL, backup.FormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			ctx := context.TODO()
+			source := MockSourceStorage(t)
+
+			var exported bytes.Buffer
+			writer, err := backup.NewRecordWriter(&exported, format)
+			assert.NoError(t, err)
+			count, err := backup.Export(ctx, source, writer)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(3), count)
+
+			destination := MockRedisStorage(t)
+			reader, err := backup.NewRecordReader(bytes.NewReader(exported.Bytes()), format)
+			assert.NoError(t, err)
+			report, err := backup.Import(ctx, destination, reader, backup.ConflictFail)
+			assert.NoError(t, err)
+			assert.Equal(t, backup.ImportReport{Imported: 3}, report)
+
+			for _, shortUrl := range []string{"cosmos", "dyna", "legacy"} {
+				want, err := source.RetrieveUrlMapping(ctx, shortUrl)
+				assert.NoError(t, err)
+				got, err := destination.RetrieveUrlMapping(ctx, shortUrl)
+				assert.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+
+			_, err = destination.RetrieveInitialUrl(ctx, "dyna")
+			assert.ErrorIs(t, err, store.ErrUrlDeleted)
+			page, err := destination.ListUrlMappings(ctx, "e0dba740", store.ListQuery{})
+			assert.NoError(t, err)
+			assert.Len(t, page.Mappings, 1)
+		})
+	}
+}
+
+func TestImportConflicts(t *testing.T) {
+	ctx := context.TODO()
+	jsonl := `{"short_url":"cosmos","original_url":"https://ultra.fandom.com/wiki/Ultraman_Cosmos"}
+{"short_url":"gaia","original_url":"https://ultra.fandom.com/wiki/Ultraman_Gaia"}
+`
+	newStorage := func() store.StorageServiceI {
+		storage := MockRedisStorage(t)
+		assert.NoError(t, storage.CreateUrlMapping(ctx, "cosmos", store.UrlMapping{OriginalUrl: "https://ultra.fandom.com/wiki/Ultraman_Justice"}))
+		return storage
+	}
+	importJSONL := func(storage store.StorageServiceI, conflict string) (backup.ImportReport, error) {
+		reader, err := backup.NewRecordReader(strings.NewReader(jsonl), backup.FormatJSONL)
+		assert.NoError(t, err)
+		return backup.Import(ctx, storage, reader, conflict)
+	}
+
+	storage := newStorage()
+	report, err := importJSONL(storage, backup.ConflictSkip)
+	assert.NoError(t, err)
+	assert.Equal(t, backup.ImportReport{Imported: 1, Skipped: 1}, report)
+	longUrl, err := storage.RetrieveInitialUrl(ctx, "cosmos")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Justice", longUrl)
+
+	storage = newStorage()
+	report, err = importJSONL(storage, backup.ConflictOverwrite)
+	assert.NoError(t, err)
+	assert.Equal(t, backup.ImportReport{Imported: 1, Overwritten: 1}, report)
+	longUrl, err = storage.RetrieveInitialUrl(ctx, "cosmos")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Cosmos", longUrl)
+
+	storage = newStorage()
+	report, err = importJSONL(storage, backup.ConflictFail)
+	assert.ErrorIs(t, err, backup.ErrConflict)
+	assert.Contains(t, err.Error(), "line 1")
+	assert.Equal(t, backup.ImportReport{}, report)
+	assert.False(t, storage.CheckIfShortUrlExists(ctx, "gaia"))
+}
+
+func TestImportInvalidRecord(t *testing.T) {
+	ctx := context.TODO()
+	storage := store.NewMemoryStorageService()
+
+	for _, jsonl := range []string{
+		"{\"short_url\":\"cosmos\",\"original_url\":\"https://ultra.fandom.com/wiki/Ultraman_Cosmos\"}\n{\"short_url\":\"apikey:x\",\"original_url\":\"https://ultra.fandom.com\"}\n",
+		"{\"short_url\":\"cosmos\",\"original_url\":\"https://ultra.fandom.com/wiki/Ultraman_Cosmos\"}\n{\"short_url\":\n",
+		"{\"short_url\":\"cosmos\",\"original_url\":\"https://ultra.fandom.com/wiki/Ultraman_Cosmos\"}\n{\"short_url\":\"gaia\"}\n",
+	} {
+		reader, err := backup.NewRecordReader(strings.NewReader(jsonl), backup.FormatJSONL)
+		assert.NoError(t, err)
+		_, err = backup.Import(ctx, storage, reader, backup.ConflictSkip)
+		assert.ErrorIs(t, err, backup.ErrInvalidRecord)
+		assert.Contains(t, err.Error(), "line 2")
+	}
+	assert.True(t, storage.CheckIfShortUrlExists(ctx, "cosmos"))
+	assert.False(t, storage.CheckIfShortUrlExists(ctx, "gaia"))
+}
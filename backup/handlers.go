@@ -0,0 +1,88 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+var contentTypes = map[string]string{
+	FormatJSONL: "application/x-ndjson",
+	FormatCSV:   "text/csv",
+}
+
+type HandlerI interface {
+	Export(c *gin.Context)
+	Import(c *gin.Context)
+}
+
+type handler struct {
+	storage store.StorageServiceI
+}
+
+func NewHandler(storage store.StorageServiceI) HandlerI {
+	return &handler{
+		storage: storage,
+	}
+}
+
+// Export streams every mapping as a download. Once the first record is sent
+// the status can't change anymore, so a failure past that point only cuts
+// the download short.
+func (h *handler) Export(c *gin.Context) {
+	format := c.DefaultQuery("format", FormatJSONL)
+	if !IsValidFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input a format of jsonl or csv!"})
+		return
+	}
+
+	c.Header("Content-Type", contentTypes[format])
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="url-blaster-%s.%s"`, time.Now().UTC().Format("20060102T150405Z"), format))
+	c.Status(http.StatusOK)
+
+	writer, _ := NewRecordWriter(c.Writer, format)
+	exported, err := Export(c, h.storage, writer)
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed exporting url mappings | Error: %v - exported: %d", err, exported))
+		return
+	}
+	log.Info().Msg(fmt.Sprintf("Exported url mappings | format: %s - exported: %d", format, exported))
+}
+
+// Import reads the body as an export in the given format. An import stopped
+// by a conflict or an invalid record still reports what was imported before.
+func (h *handler) Import(c *gin.Context) {
+	format := c.DefaultQuery("format", FormatJSONL)
+	if !IsValidFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input a format of jsonl or csv!"})
+		return
+	}
+	conflict := c.DefaultQuery("conflict", ConflictSkip)
+	if !IsValidConflict(conflict) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input a conflict of skip, overwrite or fail!"})
+		return
+	}
+
+	reader, _ := NewRecordReader(c.Request.Body, format)
+	report, err := Import(c, h.storage, reader, conflict)
+	if errors.Is(err, ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "report": report})
+		return
+	}
+	if errors.Is(err, ErrInvalidRecord) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "report": report})
+		return
+	}
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed importing url mappings | Error: %v - imported: %d", err, report.Imported))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "report": report})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
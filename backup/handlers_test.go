@@ -0,0 +1,66 @@
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/backup"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func MockBackupRouter(storage store.StorageServiceI) *gin.Engine {
+	h := backup.NewHandler(storage)
+	router := gin.New()
+	router.GET("/admin/export", h.Export)
+	router.POST("/admin/import", h.Import)
+	return router
+}
+
+func TestExportAndImportEndpoints(t *testing.T) {
+	w := httptest.NewRecorder()
+	MockBackupRouter(MockSourceStorage(t)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/export?format=csv", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), ".csv")
+	assert.Equal(t, 4, strings.Count(w.Body.String(), "\n"))
+	exported := w.Body.Bytes()
+
+	destination := store.NewMemoryStorageService()
+	router := MockBackupRouter(destination)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/import?format=csv", bytes.NewReader(exported)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var report backup.ImportReport
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, backup.ImportReport{Imported: 3}, report)
+
+	longUrl, err := destination.RetrieveInitialUrl(context.TODO(), "cosmos")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Cosmos", longUrl)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/import?format=csv&conflict=fail", bytes.NewReader(exported)))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/import?format=csv", strings.NewReader("short_url,original_url\ngaia,\n")))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "line 2")
+
+	for _, path := range []string{"/admin/export?format=xml", "/admin/import?conflict=merge"} {
+		w = httptest.NewRecorder()
+		method := http.MethodGet
+		if strings.HasPrefix(path, "/admin/import") {
+			method = http.MethodPost
+		}
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader("")))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
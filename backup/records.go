@@ -0,0 +1,264 @@
+package backup
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// maxRecordBytes bounds one line of a JSONL import.
+const maxRecordBytes = 1 << 20
+
+// csvColumns is the header of a CSV export. Tags are a JSON array, as tags
+// can hold commas, and times are RFC 3339.
+var csvColumns = []string{
+	"short_url",
+	"original_url",
+	"owner",
+	"expires_at",
+	"redirect_type",
+	"forward_query",
+	"forward_path",
+	"query_precedence",
+	"title",
+	"tags",
+	"created_at",
+	"updated_at",
+	"deleted_at",
+}
+
+type RecordWriterI interface {
+	Write(record Record) error
+	Flush() error
+}
+
+// RecordReaderI gives io.EOF after the last record. Position tells where the
+// last record read is, for errors.
+type RecordReaderI interface {
+	Read() (Record, error)
+	Position() string
+}
+
+func NewRecordWriter(w io.Writer, format string) (RecordWriterI, error) {
+	switch format {
+	case FormatJSONL:
+		buffered := bufio.NewWriter(w)
+		return &jsonlWriter{buffered: buffered, encoder: json.NewEncoder(buffered)}, nil
+	case FormatCSV:
+		return &csvWriter{writer: csv.NewWriter(w)}, nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+func NewRecordReader(r io.Reader, format string) (RecordReaderI, error) {
+	switch format {
+	case FormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxRecordBytes)
+		return &jsonlReader{scanner: scanner}, nil
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		return &csvReader{reader: reader}, nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+type jsonlWriter struct {
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+}
+
+func (w *jsonlWriter) Write(record Record) error {
+	return w.encoder.Encode(record)
+}
+
+func (w *jsonlWriter) Flush() error {
+	return w.buffered.Flush()
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *jsonlReader) Read() (Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		if len(r.scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(r.scanner.Bytes(), &record); err != nil {
+			return Record{}, fmt.Errorf("%s: %w", r.Position(), err)
+		}
+		return record, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
+
+func (r *jsonlReader) Position() string {
+	return fmt.Sprintf("line %d", r.line)
+}
+
+type csvWriter struct {
+	writer      *csv.Writer
+	wroteHeader bool
+}
+
+func (w *csvWriter) Write(record Record) error {
+	if !w.wroteHeader {
+		if err := w.writer.Write(csvColumns); err != nil {
+			return err
+		}
+		w.wroteHeader = true
+	}
+
+	tags := ""
+	if len(record.Tags) > 0 {
+		encoded, err := json.Marshal(record.Tags)
+		if err != nil {
+			return err
+		}
+		tags = string(encoded)
+	}
+
+	redirectType := ""
+	if record.RedirectType != 0 {
+		redirectType = strconv.Itoa(record.RedirectType)
+	}
+
+	return w.writer.Write([]string{
+		record.ShortUrl,
+		record.OriginalUrl,
+		record.Owner,
+		formatCsvTime(record.ExpiresAt),
+		redirectType,
+		strconv.FormatBool(record.ForwardQuery),
+		strconv.FormatBool(record.ForwardPath),
+		record.QueryPrecedence,
+		record.Title,
+		tags,
+		formatCsvTime(record.CreatedAt),
+		formatCsvTime(record.UpdatedAt),
+		formatCsvTime(record.DeletedAt),
+	})
+}
+
+// Flush writes the header even without any record, so an empty export is
+// still a valid CSV.
+func (w *csvWriter) Flush() error {
+	if !w.wroteHeader {
+		if err := w.writer.Write(csvColumns); err != nil {
+			return err
+		}
+		w.wroteHeader = true
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	line    int
+}
+
+func (r *csvReader) Read() (Record, error) {
+	if r.columns == nil {
+		header, err := r.reader.Read()
+		if err != nil {
+			return Record{}, err
+		}
+		r.columns = map[string]int{}
+		for i, name := range header {
+			r.columns[name] = i
+		}
+		r.line, _ = r.reader.FieldPos(0)
+	}
+
+	fields, err := r.reader.Read()
+	if err != nil {
+		return Record{}, err
+	}
+	r.line, _ = r.reader.FieldPos(0)
+
+	field := func(name string) string {
+		i, ok := r.columns[name]
+		if !ok || i >= len(fields) {
+			return ""
+		}
+		return fields[i]
+	}
+
+	record := Record{ShortUrl: field("short_url")}
+	record.OriginalUrl = field("original_url")
+	record.Owner = field("owner")
+	record.QueryPrecedence = field("query_precedence")
+	record.Title = field("title")
+
+	if value := field("redirect_type"); value != "" {
+		if record.RedirectType, err = strconv.Atoi(value); err != nil {
+			return Record{}, fmt.Errorf("%s: redirect_type: %w", r.Position(), err)
+		}
+	}
+	if value := field("forward_query"); value != "" {
+		if record.ForwardQuery, err = strconv.ParseBool(value); err != nil {
+			return Record{}, fmt.Errorf("%s: forward_query: %w", r.Position(), err)
+		}
+	}
+	if value := field("forward_path"); value != "" {
+		if record.ForwardPath, err = strconv.ParseBool(value); err != nil {
+			return Record{}, fmt.Errorf("%s: forward_path: %w", r.Position(), err)
+		}
+	}
+	if value := field("tags"); value != "" {
+		if err := json.Unmarshal([]byte(value), &record.Tags); err != nil {
+			return Record{}, fmt.Errorf("%s: tags: %w", r.Position(), err)
+		}
+	}
+
+	for name, destination := range map[string]**time.Time{
+		"expires_at": &record.ExpiresAt,
+		"created_at": &record.CreatedAt,
+		"updated_at": &record.UpdatedAt,
+		"deleted_at": &record.DeletedAt,
+	} {
+		if *destination, err = parseCsvTime(field(name)); err != nil {
+			return Record{}, fmt.Errorf("%s: %s: %w", r.Position(), name, err)
+		}
+	}
+	return record, nil
+}
+
+func (r *csvReader) Position() string {
+	return fmt.Sprintf("line %d", r.line)
+}
+
+func formatCsvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseCsvTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
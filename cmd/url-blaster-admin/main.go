@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"source.golabs.io/daniel.santoso/url-blaster/backup"
+	"source.golabs.io/daniel.santoso/url-blaster/config"
+	"source.golabs.io/daniel.santoso/url-blaster/migration"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+const usage = `Usage: url-blaster-admin <command> [flags]
+
+Commands:
+  migrate    apply the pending keyspace migrations to Redis
+  export     write every short url with its metadata to JSONL or CSV
+  import     create the short urls of an export in the configured storage
+
+Run url-blaster-admin <command> -h for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "migrate":
+		err = runMigrate(os.Args[2:])
+	case "export":
+		err = runExport(os.Args[2:])
+	case "import":
+		err = runImport(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("%s failed", os.Args[1]))
+		os.Exit(1)
+	}
+}
+
+func runMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	configPath := flags.String("config", "dev.application.yml", "config file of the service")
+	dryRun := flags.Bool("dry-run", false, "count what would be migrated without writing anything")
+	batchSize := flags.Int64("batch-size", migration.DefaultBatchSize, "keys scanned per batch")
+	targetVersion := flags.Int("to", 0, "stop after this migration version, 0 applies all")
+	list := flags.Bool("list", false, "list the migrations and whether they are applied")
+	includeLegacyStrings := flags.Bool("include-legacy-strings", false, "also move unprefixed strings holding a bare url in the key-prefix migration")
+	_ = flags.Parse(args)
+
+	cfg, err := config.NewConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if cfg.StorageDriver != store.RedisDriver && cfg.StorageDriver != "" {
+		return errors.New("keyspace migrations only apply to the redis storage driver")
+	}
+
+	ctx := context.Background()
+	redisClient, err := store.NewRedisClient(cfg)
+	if err != nil {
+		return err
+	}
+	defer redisClient.Close()
+
+	migrator := migration.NewMigrator(redisClient, migration.Steps(), migration.Options{
+		BatchSize:     *batchSize,
+		DryRun:        *dryRun,
+		TargetVersion: *targetVersion,
+		KeyPrefix:     store.RedisKeyPrefix(cfg),
+
+		IncludeLegacyStrings: *includeLegacyStrings,
+	})
+
+	if *list {
+		current, err := migrator.CurrentVersion(ctx)
+		if err != nil {
+			return err
+		}
+		for _, step := range migration.Steps() {
+			status := "pending"
+			if step.Version <= current {
+				status = "applied"
+			}
+			fmt.Printf("%4d  %-24s %s\n", step.Version, step.Name, status)
+		}
+		return nil
+	}
+
+	reports, err := migrator.Run(ctx)
+	for _, report := range reports {
+		fmt.Printf("%4d  %-24s migrated: %d  skipped: %d  failed: %d\n", report.Version, report.Name, report.Migrated, report.Skipped, report.Failed)
+	}
+	if err == nil && len(reports) == 0 {
+		fmt.Println("Nothing to migrate")
+	}
+	if err == nil && *dryRun {
+		fmt.Println("Dry run, nothing was written")
+	}
+	return err
+}
+
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	configPath := flags.String("config", "dev.application.yml", "config file of the service")
+	format := flags.String("format", backup.FormatJSONL, "jsonl or csv")
+	output := flags.String("out", "", "file to write, standard output when empty")
+	_ = flags.Parse(args)
+
+	if !backup.IsValidFormat(*format) {
+		return backup.ErrUnknownFormat
+	}
+
+	ctx := context.Background()
+	storage, closeStorage, err := openStorage(ctx, *configPath)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	out := os.Stdout
+	if *output != "" {
+		out, err = os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	writer, err := backup.NewRecordWriter(out, *format)
+	if err != nil {
+		return err
+	}
+	exported, err := backup.Export(ctx, storage, writer)
+	fmt.Fprintf(os.Stderr, "exported: %d\n", exported)
+	return err
+}
+
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	configPath := flags.String("config", "dev.application.yml", "config file of the service")
+	format := flags.String("format", backup.FormatJSONL, "jsonl or csv")
+	input := flags.String("in", "", "file to read, standard input when empty")
+	conflict := flags.String("conflict", backup.ConflictSkip, "what to do with short urls in use: skip, overwrite or fail")
+	_ = flags.Parse(args)
+
+	if !backup.IsValidFormat(*format) {
+		return backup.ErrUnknownFormat
+	}
+	if !backup.IsValidConflict(*conflict) {
+		return backup.ErrUnknownConflict
+	}
+
+	ctx := context.Background()
+	storage, closeStorage, err := openStorage(ctx, *configPath)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	in := os.Stdin
+	if *input != "" {
+		in, err = os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+	}
+
+	reader, err := backup.NewRecordReader(in, *format)
+	if err != nil {
+		return err
+	}
+	report, err := backup.Import(ctx, storage, reader, *conflict)
+	fmt.Fprintf(os.Stderr, "imported: %d  overwritten: %d  skipped: %d\n", report.Imported, report.Overwritten, report.Skipped)
+	return err
+}
+
+// openStorage opens the storage of a config file without the cache, the
+// service still caches what it read before an import for up to
+// CACHE_TTL_SECONDS.
+func openStorage(ctx context.Context, configPath string) (store.StorageServiceI, func(), error) {
+	cfg, err := config.NewConfig(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch cfg.StorageDriver {
+	case store.SqliteDriver, store.PostgresDriver:
+		storage, err := store.NewSqlStorageService(ctx, cfg.StorageDriver, cfg.StorageDsn)
+		if err != nil {
+			return nil, nil, err
+		}
+		return storage, func() { _ = storage.Close() }, nil
+	case store.RedisDriver, "":
+		redisClient, err := store.NewRedisClient(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		storage := &store.StorageService{
+			Cfg:         cfg,
+			RedisClient: redisClient,
+			KeyPrefix:   store.RedisKeyPrefix(cfg),
+		}
+		return storage, func() { _ = redisClient.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("the %s storage driver only lives in the service, use /admin/export and /admin/import", cfg.StorageDriver)
+	}
+}
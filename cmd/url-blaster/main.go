@@ -3,11 +3,19 @@ package main
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
+	"source.golabs.io/daniel.santoso/url-blaster/analytics"
+	"source.golabs.io/daniel.santoso/url-blaster/audit"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
+	"source.golabs.io/daniel.santoso/url-blaster/backup"
 	"source.golabs.io/daniel.santoso/url-blaster/config"
 	"source.golabs.io/daniel.santoso/url-blaster/handler"
+	"source.golabs.io/daniel.santoso/url-blaster/policy"
+	"source.golabs.io/daniel.santoso/url-blaster/ratelimit"
 	"source.golabs.io/daniel.santoso/url-blaster/shortener"
 	"source.golabs.io/daniel.santoso/url-blaster/store"
 )
@@ -19,23 +27,94 @@ func main() {
 		log.Err(err).Msg("Error while loading config")
 	}
 	ctx := context.Background()
-	store := store.NewStorageService(cfg, ctx)
-	handler := handler.NewHandler(shortener, cfg, store)
+	storageService, clickRecorder, limiter := initializeStorage(cfg, ctx)
+	defer clickRecorder.Close()
+	cache := store.NewCachedStorageService(
+		storageService,
+		cfg.CacheSize,
+		time.Duration(cfg.CacheTtlSeconds)*time.Second,
+		time.Duration(cfg.CacheNegativeTtlSeconds)*time.Second,
+	)
+	// The reaper purges through the cache, so purged short urls stop
+	// redirecting right away.
+	go store.NewReaper(
+		cache,
+		time.Duration(cfg.DeletedUrlRetentionSeconds)*time.Second,
+		time.Duration(cfg.ReaperIntervalSeconds)*time.Second,
+		store.WithPurgeListener(clickRecorder.Delete),
+	).Run(ctx)
+	// Only the redirects read from the cache, management reads must not be
+	// stale. Writes still go through it to invalidate what it holds.
+	store := cache.Uncached()
+	handlerOptions := append(initializeHandlerOptions(cfg, ctx), handler.WithRedirectStore(cache))
+	handler := handler.NewHandler(shortener, cfg, store, handlerOptions...)
+	statsHandler := analytics.NewHandler(clickRecorder, store)
+	apiKeyHandler := auth.NewHandler(store)
+	auditSink := initializeAuditSink(cfg, storageService)
 
 	router := gin.Default()
+	initializeTrustedProxies(cfg, router)
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"message": "This is the Go URL Blaster!",
 		})
 	})
 
-	router.POST("/create-short-url", handler.CreateShortUrl)
+	management := router.Group("/")
+	management.Use(ratelimit.RateLimitByClientIp(limiter, "management-ip", ratelimit.Limit{
+		Requests: cfg.RateLimitManagementIpRequests,
+		Period:   time.Duration(cfg.RateLimitManagementIpPeriodSeconds) * time.Second,
+	}))
+	if cfg.AuthDisabled {
+		log.Warn().Msg("Authentication is disabled, anyone can manage short urls")
+	} else {
+		management.Use(auth.RequireBearer(initializeAuthenticators(cfg, store)...))
+	}
+	management.Use(ratelimit.RateLimit(limiter, "management", ratelimit.Limit{
+		Requests: cfg.RateLimitManagementRequests,
+		Period:   time.Duration(cfg.RateLimitManagementPeriodSeconds) * time.Second,
+	}))
+
+	redirectLimit := ratelimit.RateLimit(limiter, "redirect", ratelimit.Limit{
+		Requests: cfg.RateLimitRedirectRequests,
+		Period:   time.Duration(cfg.RateLimitRedirectPeriodSeconds) * time.Second,
+	})
+
+	management.POST("/create-short-url", audit.Track(auditSink, audit.ActionCreate), handler.CreateShortUrl)
+	management.POST("/bulk-create-short-url", audit.Track(auditSink, audit.ActionBulkCreate), handler.BulkCreateShortUrls)
+
+	management.POST("/update-url", audit.Track(auditSink, audit.ActionUpdate), handler.UpdateLongUrl)
+
+	management.POST("/remove-url", audit.Track(auditSink, audit.ActionRemove), handler.RemoveShortUrl)
+
+	management.GET("/users/:id/links", handler.ListUserLinks)
+	management.GET("/links/:shortUrl", handler.GetLink)
+	management.GET("/links/:shortUrl/history", handler.GetLinkHistory)
+	management.POST("/links/:shortUrl/rollback", audit.Track(auditSink, audit.ActionRollback), handler.RollbackLink)
+	management.POST("/links/:shortUrl/restore", audit.Track(auditSink, audit.ActionRestore), handler.RestoreLink)
+
+	management.GET("/stats/:shortUrl", statsHandler.GetStats)
 
-	router.POST("/update-url", handler.UpdateLongUrl)
+	if cfg.AdminToken != "" {
+		admin := router.Group("/admin", auth.RequireAdminToken(cfg.AdminToken))
+		admin.POST("/create-api-key", apiKeyHandler.CreateApiKey)
+		admin.POST("/revoke-api-key", apiKeyHandler.RevokeApiKey)
+		if auditSink != nil {
+			admin.GET("/audit", audit.NewHandler(auditSink).GetAudit)
+		}
+		backupHandler := backup.NewHandler(store)
+		admin.GET("/export", backupHandler.Export)
+		admin.POST("/import", backupHandler.Import)
+		admin.GET("/cache/stats", func(c *gin.Context) {
+			c.JSON(200, cache.CacheStats())
+		})
+	} else {
+		log.Warn().Msg("ADMIN_TOKEN is not set, api keys can't be issued or revoked")
+	}
 
-	router.POST("/remove-url", handler.RemoveShortUrl)
+	router.GET("/:shortUrl", redirectLimit, analytics.TrackClicks(clickRecorder), handler.HandleShortUrlRedirect)
 
-	router.GET("/:shortUrl", handler.HandleShortUrlRedirect)
+	router.GET("/:shortUrl/*path", redirectLimit, analytics.TrackClicks(clickRecorder), handler.HandleShortUrlRedirect)
 
 	err = StartWebServer(router, cfg.ServerPort)
 	if err != nil {
@@ -43,6 +122,100 @@ func main() {
 	}
 }
 
+// initializeTrustedProxies only takes the client ip from X-Forwarded-For when
+// the request comes from one of TRUSTED_PROXIES, otherwise anyone could pick
+// the ip they are rate limited and audited by.
+func initializeTrustedProxies(cfg *config.Config, router *gin.Engine) {
+	var proxies []string
+	for _, proxy := range strings.Split(cfg.TrustedProxies, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	err := router.SetTrustedProxies(proxies)
+	if err != nil {
+		log.Fatal().Msg(fmt.Sprintf("Error setting trusted proxies: %v", err))
+	}
+}
+
+func initializeStorage(cfg *config.Config, ctx context.Context) (store.StorageServiceI, analytics.ClickRecorderI, ratelimit.LimiterI) {
+	switch cfg.StorageDriver {
+	case store.MemoryDriver:
+		log.Warn().Msg("Using in-memory storage, short urls will be lost on restart")
+		return store.NewMemoryStorageService(), analytics.NewMemoryClickRecorder(), ratelimit.NewMemoryLimiter()
+	case store.SqliteDriver, store.PostgresDriver:
+		storageService, err := store.NewSqlStorageService(ctx, cfg.StorageDriver, cfg.StorageDsn)
+		if err != nil {
+			log.Fatal().Msg(fmt.Sprintf("Error init %s storage: %v", cfg.StorageDriver, err))
+		}
+		log.Warn().Msg("Click statistics and rate limits are kept in memory with the sql storage drivers")
+		return storageService, analytics.NewMemoryClickRecorder(), ratelimit.NewMemoryLimiter()
+	case store.RedisDriver, "":
+		storageService := store.NewStorageService(cfg, ctx)
+		return storageService, analytics.NewRedisClickRecorder(storageService.RedisClient, storageService.KeyPrefix), ratelimit.NewRedisLimiter(storageService.RedisClient, storageService.KeyPrefix)
+	default:
+		log.Fatal().Msg(fmt.Sprintf("Unknown storage driver: %s", cfg.StorageDriver))
+		return nil, nil, nil
+	}
+}
+
+func initializeAuditSink(cfg *config.Config, storageService store.StorageServiceI) audit.SinkI {
+	switch cfg.AuditSink {
+	case "":
+		log.Warn().Msg("AUDIT_SINK is not set, management calls are not audited")
+		return nil
+	case audit.MemoryDriver:
+		log.Warn().Msg("Using in-memory audit sink, the audit trail will be lost on restart")
+		return audit.NewMemorySink()
+	case audit.FileDriver:
+		sink, err := audit.NewFileSink(cfg.AuditFilePath)
+		if err != nil {
+			log.Fatal().Msg(fmt.Sprintf("Error init file audit sink: %v", err))
+		}
+		return sink
+	case audit.RedisDriver:
+		redisStorage, ok := storageService.(*store.StorageService)
+		if !ok {
+			log.Fatal().Msg("The redis audit sink needs the redis storage driver")
+		}
+		return audit.NewRedisSink(redisStorage.RedisClient, redisStorage.KeyPrefix)
+	default:
+		log.Fatal().Msg(fmt.Sprintf("Unknown audit sink: %s", cfg.AuditSink))
+		return nil
+	}
+}
+
+func initializeHandlerOptions(cfg *config.Config, ctx context.Context) []handler.HandlerOption {
+	var opts []handler.HandlerOption
+
+	if cfg.DomainPolicyFile != "" {
+		domainPolicy, err := policy.NewDomainPolicy(cfg.DomainPolicyFile)
+		if err != nil {
+			log.Fatal().Msg(fmt.Sprintf("Error loading domain policy: %v", err))
+		}
+		go domainPolicy.Watch(ctx, time.Duration(cfg.DomainPolicyReloadSeconds)*time.Second)
+		opts = append(opts, handler.WithDomainPolicy(domainPolicy))
+	}
+
+	return opts
+}
+
+func initializeAuthenticators(cfg *config.Config, storage store.StorageServiceI) []auth.AuthenticatorI {
+	authenticators := []auth.AuthenticatorI{auth.NewApiKeyAuthenticator(storage)}
+
+	if cfg.JwtHs256Secret != "" || cfg.JwtJwksUrl != "" {
+		jwtAuthenticator, err := auth.NewJwtAuthenticator(cfg)
+		if err != nil {
+			log.Fatal().Msg(fmt.Sprintf("Error init jwt authentication: %v", err))
+		}
+		authenticators = append(authenticators, jwtAuthenticator)
+	}
+
+	return authenticators
+}
+
 func StartWebServer(router *gin.Engine, portNumber string) error {
 	err := router.Run(fmt.Sprintf(":%s", portNumber))
 	return err
@@ -5,11 +5,69 @@ import (
 )
 
 type Config struct {
-	AppName     string `yaml:"APP_NAME" env:"APP_NAME"`
-	ServerHost  string `yaml:"SERVER_HOST" env:"SERVER_HOST"`
-	ServerPort  string `yaml:"SERVER_PORT" env:"SERVER_PORT"`
-	StorageHost string `yaml:"STORAGE_HOST" env:"STORAGE_HOST"`
-	StoragePort string `yaml:"STORAGE_PORT" env:"STORAGE_PORT"`
+	AppName    string `yaml:"APP_NAME" env:"APP_NAME"`
+	ServerHost string `yaml:"SERVER_HOST" env:"SERVER_HOST"`
+	ServerPort string `yaml:"SERVER_PORT" env:"SERVER_PORT"`
+
+	TrustedProxies string `yaml:"TRUSTED_PROXIES" env:"TRUSTED_PROXIES"`
+
+	ShortLinkHosts   string `yaml:"SHORT_LINK_HOSTS" env:"SHORT_LINK_HOSTS"`
+	MaxRedirectChain int    `yaml:"MAX_REDIRECT_CHAIN" env:"MAX_REDIRECT_CHAIN"`
+
+	DefaultRedirectType int `yaml:"DEFAULT_REDIRECT_TYPE" env:"DEFAULT_REDIRECT_TYPE"`
+
+	UrlAllowedSchemes string `yaml:"URL_ALLOWED_SCHEMES" env:"URL_ALLOWED_SCHEMES"`
+	UrlMaxLength      int    `yaml:"URL_MAX_LENGTH" env:"URL_MAX_LENGTH"`
+	UrlStripFragment  bool   `yaml:"URL_STRIP_FRAGMENT" env:"URL_STRIP_FRAGMENT"`
+
+	DomainPolicyFile          string `yaml:"DOMAIN_POLICY_FILE" env:"DOMAIN_POLICY_FILE"`
+	DomainPolicyReloadSeconds int    `yaml:"DOMAIN_POLICY_RELOAD_SECONDS" env:"DOMAIN_POLICY_RELOAD_SECONDS"`
+
+	AuthDisabled bool   `yaml:"AUTH_DISABLED" env:"AUTH_DISABLED"`
+	AdminToken   string `yaml:"ADMIN_TOKEN" env:"ADMIN_TOKEN"`
+
+	JwtHs256Secret   string `yaml:"JWT_HS256_SECRET" env:"JWT_HS256_SECRET"`
+	JwtJwksUrl       string `yaml:"JWT_JWKS_URL" env:"JWT_JWKS_URL"`
+	JwtIssuer        string `yaml:"JWT_ISSUER" env:"JWT_ISSUER"`
+	JwtAudience      string `yaml:"JWT_AUDIENCE" env:"JWT_AUDIENCE"`
+	JwtLeewaySeconds int    `yaml:"JWT_LEEWAY_SECONDS" env:"JWT_LEEWAY_SECONDS"`
+
+	RateLimitManagementRequests        int `yaml:"RATE_LIMIT_MANAGEMENT_REQUESTS" env:"RATE_LIMIT_MANAGEMENT_REQUESTS"`
+	RateLimitManagementPeriodSeconds   int `yaml:"RATE_LIMIT_MANAGEMENT_PERIOD_SECONDS" env:"RATE_LIMIT_MANAGEMENT_PERIOD_SECONDS"`
+	RateLimitManagementIpRequests      int `yaml:"RATE_LIMIT_MANAGEMENT_IP_REQUESTS" env:"RATE_LIMIT_MANAGEMENT_IP_REQUESTS"`
+	RateLimitManagementIpPeriodSeconds int `yaml:"RATE_LIMIT_MANAGEMENT_IP_PERIOD_SECONDS" env:"RATE_LIMIT_MANAGEMENT_IP_PERIOD_SECONDS"`
+	RateLimitRedirectRequests          int `yaml:"RATE_LIMIT_REDIRECT_REQUESTS" env:"RATE_LIMIT_REDIRECT_REQUESTS"`
+	RateLimitRedirectPeriodSeconds     int `yaml:"RATE_LIMIT_REDIRECT_PERIOD_SECONDS" env:"RATE_LIMIT_REDIRECT_PERIOD_SECONDS"`
+
+	StorageDriver string `yaml:"STORAGE_DRIVER" env:"STORAGE_DRIVER"`
+	StorageDsn    string `yaml:"STORAGE_DSN" env:"STORAGE_DSN"`
+	StorageHost   string `yaml:"STORAGE_HOST" env:"STORAGE_HOST"`
+	StoragePort   string `yaml:"STORAGE_PORT" env:"STORAGE_PORT"`
+
+	RedisMode                  string `yaml:"REDIS_MODE" env:"REDIS_MODE"`
+	RedisAddresses             string `yaml:"REDIS_ADDRESSES" env:"REDIS_ADDRESSES"`
+	RedisMasterName            string `yaml:"REDIS_MASTER_NAME" env:"REDIS_MASTER_NAME"`
+	RedisUsername              string `yaml:"REDIS_USERNAME" env:"REDIS_USERNAME"`
+	RedisPassword              string `yaml:"REDIS_PASSWORD" env:"REDIS_PASSWORD"`
+	RedisSentinelPassword      string `yaml:"REDIS_SENTINEL_PASSWORD" env:"REDIS_SENTINEL_PASSWORD"`
+	RedisDb                    int    `yaml:"REDIS_DB" env:"REDIS_DB"`
+	RedisTlsEnabled            bool   `yaml:"REDIS_TLS_ENABLED" env:"REDIS_TLS_ENABLED"`
+	RedisTlsInsecureSkipVerify bool   `yaml:"REDIS_TLS_INSECURE_SKIP_VERIFY" env:"REDIS_TLS_INSECURE_SKIP_VERIFY"`
+	RedisPoolSize              int    `yaml:"REDIS_POOL_SIZE" env:"REDIS_POOL_SIZE"`
+	RedisMinIdleConns          int    `yaml:"REDIS_MIN_IDLE_CONNS" env:"REDIS_MIN_IDLE_CONNS"`
+	RedisKeyPrefix             string `yaml:"REDIS_KEY_PREFIX" env:"REDIS_KEY_PREFIX"`
+
+	AuditSink     string `yaml:"AUDIT_SINK" env:"AUDIT_SINK"`
+	AuditFilePath string `yaml:"AUDIT_FILE_PATH" env:"AUDIT_FILE_PATH"`
+
+	DeletedUrlRetentionSeconds int `yaml:"DELETED_URL_RETENTION_SECONDS" env:"DELETED_URL_RETENTION_SECONDS"`
+	ReaperIntervalSeconds      int `yaml:"REAPER_INTERVAL_SECONDS" env:"REAPER_INTERVAL_SECONDS"`
+
+	BulkConcurrency int `yaml:"BULK_CONCURRENCY" env:"BULK_CONCURRENCY"`
+
+	CacheSize               int `yaml:"CACHE_SIZE" env:"CACHE_SIZE"`
+	CacheTtlSeconds         int `yaml:"CACHE_TTL_SECONDS" env:"CACHE_TTL_SECONDS"`
+	CacheNegativeTtlSeconds int `yaml:"CACHE_NEGATIVE_TTL_SECONDS" env:"CACHE_NEGATIVE_TTL_SECONDS"`
 }
 
 func NewConfig(filename string) (*Config, error) {
@@ -0,0 +1,336 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"source.golabs.io/daniel.santoso/url-blaster/audit"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+const (
+	maxBulkRows            = 10000
+	maxBulkBodyBytes       = 16 << 20
+	bulkChunkSize          = 100
+	defaultBulkConcurrency = 4
+)
+
+// Values of BulkRowResult.Status.
+const (
+	BulkRowCreated  = "created"
+	BulkRowConflict = "conflict"
+	BulkRowInvalid  = "invalid"
+	BulkRowFailed   = "error"
+)
+
+// BulkRowResult is the outcome of one row of a bulk creation. Row counts from
+// 1, not counting the header of a CSV.
+type BulkRowResult struct {
+	Row      int        `json:"row"`
+	Status   string     `json:"status"`
+	ShortUrl string     `json:"short_url,omitempty"`
+	LongUrl  string     `json:"long_url,omitempty"`
+	Expires  *time.Time `json:"expires_at,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// bulkRow is a row as parsed, a row that failed parsing only has err.
+type bulkRow struct {
+	request UrlCreationRequest
+	err     error
+}
+
+// BulkCreateShortUrls creates a short url for every row of a JSON array of
+// creation requests or of an uploaded CSV. Rows are validated and created
+// independently, chunks of them in parallel, and each gets its own result; a
+// failing row doesn't fail the others.
+func (h *handler) BulkCreateShortUrls(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBulkBodyBytes)
+
+	rows, err := parseBulkRows(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input at least one row!"})
+		return
+	}
+	if len(rows) > maxBulkRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Please input at most %d rows!", maxBulkRows)})
+		return
+	}
+
+	defaultUserId := requestUserId(c, c.Query("user_id"))
+	audit.Describe(c).Actor = defaultUserId
+	for i := range rows {
+		if rows[i].request.UserId == "" {
+			rows[i].request.UserId = defaultUserId
+		}
+		rows[i].request.UserId = requestUserId(c, rows[i].request.UserId)
+	}
+
+	results := make([]BulkRowResult, len(rows))
+	chunks := make(chan int)
+	var workers sync.WaitGroup
+	for i := 0; i < h.bulkConcurrency(); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for start := range chunks {
+				end := start + bulkChunkSize
+				if end > len(rows) {
+					end = len(rows)
+				}
+				h.createBulkChunk(c.Request.Context(), rows[start:end], results[start:end], start)
+			}
+		}()
+	}
+	for start := 0; start < len(rows); start += bulkChunkSize {
+		chunks <- start
+	}
+	close(chunks)
+	workers.Wait()
+
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.Status]++
+	}
+	c.JSON(200, gin.H{
+		"created":   counts[BulkRowCreated],
+		"conflicts": counts[BulkRowConflict],
+		"invalid":   counts[BulkRowInvalid],
+		"failed":    counts[BulkRowFailed],
+		"results":   results,
+	})
+}
+
+// createBulkChunk validates the rows and creates the valid ones in one batch.
+// Generated short urls start with their first candidate; only those found
+// taken go through createGeneratedUrlMapping one by one.
+func (h *handler) createBulkChunk(ctx context.Context, rows []bulkRow, results []BulkRowResult, offset int) {
+	now := time.Now().UTC()
+	var batch []store.NewUrlMapping
+	var batched []int
+
+	for i, row := range rows {
+		results[i] = BulkRowResult{Row: offset + i + 1, LongUrl: row.request.LongUrl}
+		if row.err != nil {
+			results[i].invalid(row.err)
+			continue
+		}
+		if row.request.LongUrl == "" {
+			results[i].invalid(errors.New("Please input a long_url!"))
+			continue
+		}
+
+		mapping, err := h.newUrlMapping(row.request, now)
+		if err != nil {
+			results[i].invalid(err)
+			continue
+		}
+		results[i].LongUrl = mapping.OriginalUrl
+		results[i].Expires = mapping.ExpiresAt
+
+		shortUrl := row.request.PredefinedName
+		if shortUrl == "" {
+			shortUrl, err = h.shortener.GenerateSaltedShortLink(mapping.OriginalUrl, mapping.Owner, 0)
+			if err != nil {
+				results[i].failed(err)
+				continue
+			}
+		}
+
+		err = h.validateRedirectChain(ctx, shortUrl, mapping.OriginalUrl)
+		if isRedirectChainError(err) {
+			results[i].invalid(err)
+			continue
+		}
+		if err != nil {
+			results[i].failed(err)
+			continue
+		}
+
+		results[i].ShortUrl = shortUrl
+		batch = append(batch, store.NewUrlMapping{ShortUrl: shortUrl, Mapping: mapping})
+		batched = append(batched, i)
+	}
+
+	if len(batch) == 0 {
+		return
+	}
+
+	errs := h.store.CreateUrlMappings(ctx, batch)
+	for j, i := range batched {
+		mapping := batch[j].Mapping
+		err := errs[j]
+		created := err == nil
+
+		if errors.Is(err, store.ErrShortUrlTaken) {
+			if rows[i].request.PredefinedName != "" {
+				results[i].Status = BulkRowConflict
+				results[i].Error = "Short url is already taken!"
+				continue
+			}
+			results[i].ShortUrl, created, err = h.createGeneratedUrlMapping(ctx, mapping.Owner, &mapping)
+			results[i].Expires = mapping.ExpiresAt
+		}
+		if isRedirectChainError(err) {
+			results[i].invalid(err)
+			continue
+		}
+		if err != nil {
+			log.Err(err).Msg(fmt.Sprintf("Failed saving key url | Error: %v - shortUrl: %s - originalUrl: %s", err, results[i].ShortUrl, mapping.OriginalUrl))
+			results[i].failed(err)
+			continue
+		}
+
+		if created {
+			h.recordVersion(ctx, results[i].ShortUrl, store.UrlMappingVersion{NewUrl: mapping.OriginalUrl, ChangedBy: mapping.Owner, ChangedAt: now})
+		}
+		results[i].Status = BulkRowCreated
+		results[i].ShortUrl = h.publicShortUrl(results[i].ShortUrl)
+	}
+}
+
+func (r *BulkRowResult) invalid(err error) {
+	r.Status = BulkRowInvalid
+	r.ShortUrl = ""
+	r.Error = err.Error()
+}
+
+func (r *BulkRowResult) failed(err error) {
+	r.Status = BulkRowFailed
+	r.ShortUrl = ""
+	r.Error = err.Error()
+}
+
+func (h *handler) bulkConcurrency() int {
+	if h.cfg.BulkConcurrency > 0 {
+		return h.cfg.BulkConcurrency
+	}
+	return defaultBulkConcurrency
+}
+
+// parseBulkRows reads a JSON array of creation requests, a CSV body or a CSV
+// uploaded as the "file" field of a form.
+func parseBulkRows(c *gin.Context) ([]bulkRow, error) {
+	switch c.ContentType() {
+	case gin.MIMEJSON:
+		var requests []UrlCreationRequest
+		if err := json.NewDecoder(c.Request.Body).Decode(&requests); err != nil {
+			return nil, fmt.Errorf("Please input a JSON array of short urls to create! %v", err)
+		}
+		rows := make([]bulkRow, len(requests))
+		for i, request := range requests {
+			rows[i].request = request
+		}
+		return rows, nil
+	case "text/csv":
+		return parseBulkCsv(c.Request.Body)
+	case gin.MIMEMultipartPOSTForm:
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, errors.New("Please upload the CSV as the file field!")
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return parseBulkCsv(file)
+	default:
+		return nil, errors.New("Please input a JSON array or a CSV file!")
+	}
+}
+
+// parseBulkCsv needs a header naming the columns: long_url and optionally
+// predefined_name, tags (separated by commas), expires_at (RFC 3339),
+// ttl_seconds, title and user_id. Other columns are ignored.
+func parseBulkCsv(body io.Reader) ([]bulkRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Please input a valid CSV! %v", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["long_url"]; !ok {
+		return nil, errors.New("Please input a CSV with a long_url column!")
+	}
+
+	var rows []bulkRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Please input a valid CSV! %v", err)
+		}
+		if len(rows) == maxBulkRows {
+			return nil, fmt.Errorf("Please input at most %d rows!", maxBulkRows)
+		}
+		rows = append(rows, parseBulkCsvRecord(columns, record))
+	}
+}
+
+func parseBulkCsvRecord(columns map[string]int, record []string) bulkRow {
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row := bulkRow{request: UrlCreationRequest{
+		LongUrl:        field("long_url"),
+		PredefinedName: field("predefined_name"),
+		Title:          field("title"),
+		UserId:         field("user_id"),
+	}}
+
+	if tags := field("tags"); tags != "" {
+		row.request.Tags = strings.Split(tags, ",")
+	}
+
+	if value := field("expires_at"); value != "" {
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			row.err = errors.New("Please input expires_at as an RFC 3339 time!")
+			return row
+		}
+		row.request.ExpiresAt = &expiresAt
+	}
+
+	if value := field("ttl_seconds"); value != "" {
+		ttlSeconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			row.err = errors.New("Please input ttl_seconds as a number!")
+			return row
+		}
+		row.request.TtlSeconds = ttlSeconds
+	}
+
+	return row
+}
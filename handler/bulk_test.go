@@ -0,0 +1,130 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/handler"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+type BulkCreateResponse struct {
+	Created   int                     `json:"created"`
+	Conflicts int                     `json:"conflicts"`
+	Invalid   int                     `json:"invalid"`
+	Failed    int                     `json:"failed"`
+	Results   []handler.BulkRowResult `json:"results"`
+}
+
+func MockBulkCsvUpload(t *testing.T, router *gin.Engine, csv string) (int, BulkCreateResponse) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "links.csv")
+	assert.NoError(t, err)
+	_, err = part.Write([]byte(csv))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/bulk-create-short-url", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	router.ServeHTTP(w, req)
+
+	var response BulkCreateResponse
+	if w.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	}
+	return w.Code, response
+}
+
+func TestBulkCreateShortUrlsFromJSON(t *testing.T) {
+	router := MockLinksRouter(t, store.NewMemoryStorageService(), UserId)
+	w := MockJSONPost(router, "/create-short-url", handler.UrlCreationRequest{
+		LongUrl:        "https://ultra.fandom.com/wiki/Ultraman_Gaia",
+		PredefinedName: "gaia",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = MockJSONPost(router, "/bulk-create-short-url", []handler.UrlCreationRequest{
+		{LongUrl: "https://ultra.fandom.com/wiki/Ultraman_Tiga", PredefinedName: "tiga", Tags: []string{"heisei"}},
+		{LongUrl: "https://ultra.fandom.com/wiki/Ultraman_Dyna"},
+		{LongUrl: "https://ultra.fandom.com/wiki/Ultraman_Gaia", PredefinedName: "gaia"},
+		{LongUrl: "ultraman"},
+		{LongUrl: "https://ultra.fandom.com/wiki/Ultraman_Agul", TtlSeconds: -1},
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response BulkCreateResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.Created)
+	assert.Equal(t, 1, response.Conflicts)
+	assert.Equal(t, 2, response.Invalid)
+	assert.Len(t, response.Results, 5)
+	for i, status := range []string{handler.BulkRowCreated, handler.BulkRowCreated, handler.BulkRowConflict, handler.BulkRowInvalid, handler.BulkRowInvalid} {
+		assert.Equal(t, i+1, response.Results[i].Row)
+		assert.Equal(t, status, response.Results[i].Status)
+	}
+	assert.Empty(t, response.Results[3].ShortUrl)
+	assert.NotEmpty(t, response.Results[3].Error)
+
+	code, details := MockGetLink(t, router, "tiga")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, UserId, details.Owner)
+	assert.Equal(t, []string{"heisei"}, details.Tags)
+
+	code, history := MockGetLinkHistory(t, router, "tiga")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, history.Versions, 1)
+}
+
+func TestBulkCreateShortUrlsFromCsv(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	storageService := &store.StorageService{
+		RedisClient: redis.NewClient(&redis.Options{Addr: redisServer.Addr()}),
+	}
+	router := MockLinksRouter(t, storageService, UserId)
+
+	var csv strings.Builder
+	csv.WriteString("long_url,predefined_name,tags,expires_at,ttl_seconds\n")
+	for i := 0; i < 250; i++ {
+		fmt.Fprintf(&csv, "https://ultra.fandom.com/wiki/Episode_%d,,\"kaiju, tokusatsu\",,3600\n", i)
+	}
+	csv.WriteString("https://ultra.fandom.com/wiki/Ultraman_Zero,zero,,2000-01-01T00:00:00Z,\n")
+	csv.WriteString("https://ultra.fandom.com/wiki/Ultraman_Z,z,,tomorrow,\n")
+
+	code, response := MockBulkCsvUpload(t, router, csv.String())
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 250, response.Created)
+	assert.Equal(t, 2, response.Invalid)
+	assert.Len(t, response.Results, 252)
+	assert.Equal(t, "Please input expires_at as an RFC 3339 time!", response.Results[251].Error)
+
+	shortUrl := response.Results[100].ShortUrl[strings.LastIndex(response.Results[100].ShortUrl, "/")+1:]
+	code, details := MockGetLink(t, router, shortUrl)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Episode_100", details.LongUrl)
+	assert.Equal(t, []string{"kaiju", "tokusatsu"}, details.Tags)
+	assert.NotNil(t, details.ExpiresAt)
+
+	page, err := storageService.ListUrlMappings(context.TODO(), UserId, store.ListQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, page.Mappings, 10)
+}
+
+func TestBulkCreateShortUrlsWithoutLongUrlColumn(t *testing.T) {
+	router := MockLinksRouter(t, store.NewMemoryStorageService(), UserId)
+
+	code, _ := MockBulkCsvUpload(t, router, "url,predefined_name\nhttps://ultra.fandom.com/wiki/Ultraman_Ace,ace\n")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
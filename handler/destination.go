@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+// buildDestination gives the url to redirect to. Depending on the mapping the
+// query of the incoming request is merged into the original url and the path
+// after the short url is appended to it. extraPath is either empty or starts
+// with a slash.
+func buildDestination(mapping *store.UrlMapping, extraPath string, incomingQuery url.Values) (string, error) {
+	if (!mapping.ForwardPath || extraPath == "" || extraPath == "/") && (!mapping.ForwardQuery || len(incomingQuery) == 0) {
+		return mapping.OriginalUrl, nil
+	}
+
+	destination, err := url.Parse(mapping.OriginalUrl)
+	if err != nil {
+		return "", err
+	}
+
+	if mapping.ForwardPath && extraPath != "" {
+		// Cleaning keeps "/.." in the extra path from climbing above the
+		// original url's path.
+		cleaned := path.Clean(extraPath)
+		if strings.HasSuffix(extraPath, "/") && cleaned != "/" {
+			cleaned += "/"
+		}
+		destination.Path = strings.TrimSuffix(destination.Path, "/") + cleaned
+		destination.RawPath = ""
+	}
+
+	if mapping.ForwardQuery && len(incomingQuery) > 0 {
+		destination.RawQuery = mergeQuery(destination.Query(), incomingQuery, mapping.QueryPrecedence).Encode()
+	}
+
+	return destination.String(), nil
+}
+
+func mergeQuery(destination, incoming url.Values, precedence string) url.Values {
+	for key, values := range incoming {
+		switch precedence {
+		case store.QueryPrecedenceDestination:
+			if _, ok := destination[key]; !ok {
+				destination[key] = values
+			}
+		case store.QueryPrecedenceAppend:
+			destination[key] = append(destination[key], values...)
+		default:
+			destination[key] = values
+		}
+	}
+	return destination
+}
+
+func isValidQueryPrecedence(precedence string) bool {
+	switch precedence {
+	case "", store.QueryPrecedenceIncoming, store.QueryPrecedenceDestination, store.QueryPrecedenceAppend:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/config"
+	"source.golabs.io/daniel.santoso/url-blaster/handler"
+	"source.golabs.io/daniel.santoso/url-blaster/shortener"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func MockRedirect(t *testing.T, mapping store.UrlMapping, target string) *httptest.ResponseRecorder {
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+
+	storageService := store.NewMemoryStorageService()
+	err = storageService.SaveUrlMapping(context.TODO(), "docs", mapping)
+	assert.NoError(t, err)
+
+	h := handler.NewHandler(shortener.NewShortener(), cfg, storageService)
+	router := gin.New()
+	router.GET("/:shortUrl", h.HandleShortUrlRedirect)
+	router.GET("/:shortUrl/*path", h.HandleShortUrlRedirect)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+func TestRedirectForwardPath(t *testing.T) {
+	mapping := store.UrlMapping{OriginalUrl: "https://docs.example.com/v2/?lang=en", ForwardPath: true}
+
+	targets := map[string]string{
+		"/docs":                    "https://docs.example.com/v2/?lang=en",
+		"/docs/":                   "https://docs.example.com/v2/?lang=en",
+		"/docs/guide/setup":        "https://docs.example.com/v2/guide/setup?lang=en",
+		"/docs/guide/":             "https://docs.example.com/v2/guide/?lang=en",
+		"/docs/../../admin":        "https://docs.example.com/v2/admin?lang=en",
+		"/docs/a%20b":              "https://docs.example.com/v2/a%20b?lang=en",
+		"/docs/guide/./../intro":   "https://docs.example.com/v2/intro?lang=en",
+		"/docs/guide?utm_source=x": "https://docs.example.com/v2/guide?lang=en",
+	}
+
+	for target, expected := range targets {
+		w := MockRedirect(t, mapping, target)
+		assert.Equal(t, http.StatusFound, w.Code, target)
+		assert.Equal(t, expected, w.Header().Get("Location"), target)
+	}
+}
+
+func TestRedirectForwardQuery(t *testing.T) {
+	precedences := map[string]string{
+		"":                               "https://example.com/?lang=id&utm_source=x",
+		store.QueryPrecedenceIncoming:    "https://example.com/?lang=id&utm_source=x",
+		store.QueryPrecedenceDestination: "https://example.com/?lang=en&utm_source=x",
+		store.QueryPrecedenceAppend:      "https://example.com/?lang=en&lang=id&utm_source=x",
+	}
+
+	for precedence, expected := range precedences {
+		mapping := store.UrlMapping{OriginalUrl: "https://example.com/?lang=en", ForwardQuery: true, QueryPrecedence: precedence}
+
+		w := MockRedirect(t, mapping, "/docs?utm_source=x&lang=id")
+		assert.Equal(t, http.StatusFound, w.Code, precedence)
+		assert.Equal(t, expected, w.Header().Get("Location"), precedence)
+	}
+}
+
+func TestRedirectForwardQueryWithoutIncomingQuery(t *testing.T) {
+	mapping := store.UrlMapping{OriginalUrl: "https://example.com/?b=2&a=1", ForwardQuery: true}
+
+	w := MockRedirect(t, mapping, "/docs")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/?b=2&a=1", w.Header().Get("Location"))
+}
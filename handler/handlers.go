@@ -1,51 +1,118 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"strings"
+	"net/url"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
+	"source.golabs.io/daniel.santoso/url-blaster/audit"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
 	"source.golabs.io/daniel.santoso/url-blaster/config"
+	"source.golabs.io/daniel.santoso/url-blaster/normalizer"
+	"source.golabs.io/daniel.santoso/url-blaster/policy"
 	"source.golabs.io/daniel.santoso/url-blaster/shortener"
 	"source.golabs.io/daniel.santoso/url-blaster/store"
 )
 
+const (
+	maxTtlSeconds         = 100 * 365 * 24 * 60 * 60
+	maxGenerationAttempts = 5
+)
+
 type HandlerI interface {
 	CreateShortUrl(c *gin.Context)
+	BulkCreateShortUrls(c *gin.Context)
 	UpdateLongUrl(c *gin.Context)
 	HandleShortUrlRedirect(c *gin.Context)
 	RemoveShortUrl(c *gin.Context)
+	ListUserLinks(c *gin.Context)
+	GetLink(c *gin.Context)
+	GetLinkHistory(c *gin.Context)
+	RollbackLink(c *gin.Context)
+	RestoreLink(c *gin.Context)
 }
 
 type handler struct {
-	cfg       *config.Config
-	shortener shortener.ShortenerI
-	store     store.StorageServiceI
+	cfg           *config.Config
+	shortener     shortener.ShortenerI
+	normalizer    normalizer.NormalizerI
+	domainPolicy  policy.DomainPolicyI
+	store         store.StorageServiceI
+	redirectStore store.StorageServiceI
+}
+
+type HandlerOption func(*handler)
+
+// WithDomainPolicy rejects destinations the policy blocks, both when a link
+// is created or updated and when it is followed.
+func WithDomainPolicy(domainPolicy policy.DomainPolicyI) HandlerOption {
+	return func(h *handler) {
+		h.domainPolicy = domainPolicy
+	}
+}
+
+// WithRedirectStore makes the redirects read from another storage, e.g. a
+// cached one. Everything else keeps reading from the handler's storage.
+func WithRedirectStore(redirectStore store.StorageServiceI) HandlerOption {
+	return func(h *handler) {
+		h.redirectStore = redirectStore
+	}
 }
 
 type UrlCreationRequest struct {
-	LongUrl        string `json:"long_url" binding:"required"`
-	UserId         string `json:"user_id"`
-	PredefinedName string `json:"predefined_name"`
+	LongUrl        string     `json:"long_url" binding:"required"`
+	UserId         string     `json:"user_id"`
+	PredefinedName string     `json:"predefined_name"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	TtlSeconds     int64      `json:"ttl_seconds,omitempty"`
+	RedirectType   int        `json:"redirect_type,omitempty"`
+
+	ForwardQuery    bool   `json:"forward_query,omitempty"`
+	ForwardPath     bool   `json:"forward_path,omitempty"`
+	QueryPrecedence string `json:"query_precedence,omitempty"`
+
+	Title string   `json:"title,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
 }
 
 type UrlUpdateRequest struct {
-	ShortUrl   string `json:"short_url" binding:"required"`
-	NewLongUrl string `json:"new_long_url" binding:"required"`
+	ShortUrl     string     `json:"short_url" binding:"required"`
+	NewLongUrl   string     `json:"new_long_url" binding:"required"`
+	UserId       string     `json:"user_id"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	TtlSeconds   int64      `json:"ttl_seconds,omitempty"`
+	RedirectType int        `json:"redirect_type,omitempty"`
+
+	ForwardQuery    *bool  `json:"forward_query,omitempty"`
+	ForwardPath     *bool  `json:"forward_path,omitempty"`
+	QueryPrecedence string `json:"query_precedence,omitempty"`
+
+	Title *string   `json:"title,omitempty"`
+	Tags  *[]string `json:"tags,omitempty"`
 }
 
 type UrlRemoveRequest struct {
 	ShortUrl string `json:"short_url" binding:"required"`
+	UserId   string `json:"user_id"`
 }
 
-func NewHandler(shortener shortener.ShortenerI, cfg *config.Config, store store.StorageServiceI) HandlerI {
-	return &handler{
-		cfg:       cfg,
-		shortener: shortener,
-		store:     store,
+func NewHandler(shortener shortener.ShortenerI, cfg *config.Config, store store.StorageServiceI, opts ...HandlerOption) HandlerI {
+	h := &handler{
+		cfg:        cfg,
+		shortener:  shortener,
+		normalizer: normalizer.NewNormalizer(cfg),
+		store:      store,
+	}
+	h.redirectStore = store
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 func (h *handler) CreateShortUrl(c *gin.Context) {
@@ -54,42 +121,158 @@ func (h *handler) CreateShortUrl(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	creationRequest.UserId = requestUserId(c, creationRequest.UserId)
+	change := audit.Describe(c)
+	change.Actor = creationRequest.UserId
+	change.ShortCode = creationRequest.PredefinedName
 
-	if !strings.HasPrefix(creationRequest.LongUrl, "https://") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input a valid url!"})
-		return
-	}
-
-	if creationRequest.UserId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input a valid user id!"})
+	now := time.Now().UTC()
+	mapping, err := h.newUrlMapping(creationRequest, now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	longUrl := mapping.OriginalUrl
 
 	var shortUrl string
-	var err error
+	created := true
 	if creationRequest.PredefinedName != "" {
 		shortUrl = creationRequest.PredefinedName
-	} else {
-		shortUrl, err = h.shortener.GenerateShortLink(creationRequest.LongUrl, creationRequest.UserId)
-		if err != nil {
-			log.Err(err).Msg("Error while generating short link because error while encoding with base58")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		err = h.validateRedirectChain(c, shortUrl, longUrl)
+		if err == nil {
+			err = h.store.CreateUrlMapping(c, shortUrl, mapping)
+		}
+		if errors.Is(err, store.ErrShortUrlTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Short url is already taken!"})
 			return
 		}
+	} else {
+		shortUrl, created, err = h.createGeneratedUrlMapping(c, creationRequest.UserId, &mapping)
+	}
+	if isRedirectChainError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-
-	err = h.store.SaveUrlMapping(c, shortUrl, creationRequest.LongUrl)
 	if err != nil {
-		log.Err(err).Msg(fmt.Sprintf("Failed saving key url | Error: %v - shortUrl: %s - originalUrl: %s", err, shortUrl, creationRequest.LongUrl))
+		log.Err(err).Msg(fmt.Sprintf("Failed saving key url | Error: %v - shortUrl: %s - originalUrl: %s", err, shortUrl, longUrl))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	host := fmt.Sprintf("http://%s:%s/", h.cfg.ServerHost, h.cfg.ServerPort)
-	c.JSON(200, gin.H{
+	if created {
+		h.recordVersion(c, shortUrl, store.UrlMappingVersion{NewUrl: longUrl, ChangedBy: mapping.Owner, ChangedAt: now})
+	}
+	change.ShortCode = shortUrl
+	change.After = &mapping
+
+	response := gin.H{
 		"message":   "short url created successfully",
-		"short_url": host + shortUrl,
-	})
+		"short_url": h.publicShortUrl(shortUrl),
+	}
+	if mapping.ExpiresAt != nil {
+		response["expires_at"] = mapping.ExpiresAt
+	}
+	c.JSON(200, response)
+}
+
+// newUrlMapping validates a creation request, whose UserId has to be resolved
+// already, into the mapping to create.
+func (h *handler) newUrlMapping(creationRequest UrlCreationRequest, now time.Time) (store.UrlMapping, error) {
+	longUrl, err := h.normalizer.Normalize(creationRequest.LongUrl)
+	if err != nil {
+		return store.UrlMapping{}, err
+	}
+
+	if err := h.checkDomain(longUrl); err != nil {
+		return store.UrlMapping{}, err
+	}
+
+	if creationRequest.UserId == "" {
+		return store.UrlMapping{}, errors.New("Please input a valid user id!")
+	}
+
+	if store.IsReservedRouteName(creationRequest.PredefinedName) {
+		return store.UrlMapping{}, fmt.Errorf("Please input a predefined_name other than %s, it is a route of the service!", creationRequest.PredefinedName)
+	}
+	if creationRequest.PredefinedName != "" && !store.IsValidShortUrl(creationRequest.PredefinedName) {
+		return store.UrlMapping{}, errors.New("Please input a predefined_name without /, { or } that doesn't start with a reserved prefix!")
+	}
+
+	expiresAt, err := resolveExpiry(creationRequest.ExpiresAt, creationRequest.TtlSeconds, now)
+	if err != nil {
+		return store.UrlMapping{}, err
+	}
+
+	if creationRequest.RedirectType != 0 && !isValidRedirectType(creationRequest.RedirectType) {
+		return store.UrlMapping{}, errors.New("Please input a redirect_type of 301, 302, 307 or 308!")
+	}
+
+	if !isValidQueryPrecedence(creationRequest.QueryPrecedence) {
+		return store.UrlMapping{}, errors.New("Please input a query_precedence of incoming, destination or append!")
+	}
+
+	if err := validateTitle(creationRequest.Title); err != nil {
+		return store.UrlMapping{}, err
+	}
+
+	tags, err := normalizeTags(creationRequest.Tags)
+	if err != nil {
+		return store.UrlMapping{}, err
+	}
+
+	return store.UrlMapping{
+		OriginalUrl:  longUrl,
+		Owner:        creationRequest.UserId,
+		ExpiresAt:    expiresAt,
+		RedirectType: creationRequest.RedirectType,
+
+		ForwardQuery:    creationRequest.ForwardQuery,
+		ForwardPath:     creationRequest.ForwardPath,
+		QueryPrecedence: creationRequest.QueryPrecedence,
+
+		Title: creationRequest.Title,
+		Tags:  tags,
+
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	}, nil
+}
+
+// createGeneratedUrlMapping never overwrites a mapping: when the generated
+// short url is taken by another url or owner, or by a removed mapping that
+// only RestoreLink may bring back, it retries with a salt. Taken by the same
+// live url and owner means the link is simply being created again; the link
+// is left as it is, mapping is set to it and created is false.
+func (h *handler) createGeneratedUrlMapping(ctx context.Context, userId string, mapping *store.UrlMapping) (string, bool, error) {
+	for salt := 0; salt < maxGenerationAttempts; salt++ {
+		shortUrl, err := h.shortener.GenerateSaltedShortLink(mapping.OriginalUrl, userId, salt)
+		if err != nil {
+			log.Err(err).Msg("Error while generating short link because error while encoding with base58")
+			return "", false, err
+		}
+
+		if err := h.validateRedirectChain(ctx, shortUrl, mapping.OriginalUrl); err != nil {
+			return shortUrl, false, err
+		}
+
+		err = h.store.CreateUrlMapping(ctx, shortUrl, *mapping)
+		if !errors.Is(err, store.ErrShortUrlTaken) {
+			return shortUrl, err == nil, err
+		}
+
+		existing, err := h.store.RetrieveUrlMapping(ctx, shortUrl)
+		if err != nil {
+			return shortUrl, false, err
+		}
+		if existing.OriginalUrl == mapping.OriginalUrl && existing.IsOwnedBy(mapping.Owner) && !existing.IsDeleted() {
+			*mapping = *existing
+			return shortUrl, false, nil
+		}
+
+		log.Warn().Msg(fmt.Sprintf("Generated short url collided with another url | shortUrl: %s - attempt: %d", shortUrl, salt+1))
+	}
+
+	return "", false, errors.New("Failed to generate an unused short url")
 }
 
 func (h *handler) UpdateLongUrl(c *gin.Context) {
@@ -98,9 +281,56 @@ func (h *handler) UpdateLongUrl(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	updateRequest.UserId = requestUserId(c, updateRequest.UserId)
+	change := audit.Describe(c)
+	change.Actor = updateRequest.UserId
+	change.ShortCode = updateRequest.ShortUrl
 
-	if !strings.HasPrefix(updateRequest.NewLongUrl, "https://") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input a valid url!"})
+	newLongUrl, err := h.normalizer.Normalize(updateRequest.NewLongUrl)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.checkDomain(newLongUrl); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	expiresAt, err := resolveExpiry(updateRequest.ExpiresAt, updateRequest.TtlSeconds, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if updateRequest.RedirectType != 0 && !isValidRedirectType(updateRequest.RedirectType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input a redirect_type of 301, 302, 307 or 308!"})
+		return
+	}
+
+	if !isValidQueryPrecedence(updateRequest.QueryPrecedence) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input a query_precedence of incoming, destination or append!"})
+		return
+	}
+
+	if updateRequest.Title != nil {
+		if err := validateTitle(*updateRequest.Title); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var tags []string
+	if updateRequest.Tags != nil {
+		tags, err = normalizeTags(*updateRequest.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if updateRequest.UserId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input a valid user id!"})
 		return
 	}
 
@@ -109,21 +339,92 @@ func (h *handler) UpdateLongUrl(c *gin.Context) {
 		return
 	}
 
-	err := h.store.SaveUrlMapping(c, updateRequest.ShortUrl, updateRequest.NewLongUrl)
+	mapping, err := h.store.RetrieveUrlMapping(c, updateRequest.ShortUrl)
 	if err != nil {
-		log.Err(err).Msg(fmt.Sprintf("Failed saving key url | Error: %v - shortUrl: %s - originalUrl: %s", err, updateRequest.ShortUrl, updateRequest.NewLongUrl))
+		log.Err(err).Msg(fmt.Sprintf("Failed retrieving url mapping | Error: %v - shortUrl: %s", err, updateRequest.ShortUrl))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(200, gin.H{
+	if !mapping.IsOwnedBy(updateRequest.UserId) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the owner of this short url!"})
+		return
+	}
+
+	if mapping.IsDeleted() {
+		c.JSON(http.StatusGone, gin.H{"error": "Short url is removed, please restore it first!"})
+		return
+	}
+
+	err = h.validateRedirectChain(c, updateRequest.ShortUrl, newLongUrl)
+	if isRedirectChainError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed resolving redirect chain | Error: %v - shortUrl: %s - originalUrl: %s", err, updateRequest.ShortUrl, newLongUrl))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := *mapping
+	now := time.Now().UTC()
+	oldLongUrl := mapping.OriginalUrl
+	mapping.OriginalUrl = newLongUrl
+	mapping.UpdatedAt = &now
+	if expiresAt != nil {
+		mapping.ExpiresAt = expiresAt
+	}
+	if updateRequest.RedirectType != 0 {
+		mapping.RedirectType = updateRequest.RedirectType
+	}
+	if updateRequest.ForwardQuery != nil {
+		mapping.ForwardQuery = *updateRequest.ForwardQuery
+	}
+	if updateRequest.ForwardPath != nil {
+		mapping.ForwardPath = *updateRequest.ForwardPath
+	}
+	if updateRequest.QueryPrecedence != "" {
+		mapping.QueryPrecedence = updateRequest.QueryPrecedence
+	}
+	if updateRequest.Title != nil {
+		mapping.Title = *updateRequest.Title
+	}
+	if updateRequest.Tags != nil {
+		mapping.Tags = tags
+	}
+
+	err = h.store.SaveUrlMapping(c, updateRequest.ShortUrl, *mapping)
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed saving key url | Error: %v - shortUrl: %s - originalUrl: %s", err, updateRequest.ShortUrl, newLongUrl))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if oldLongUrl != newLongUrl {
+		h.recordVersion(c, updateRequest.ShortUrl, store.UrlMappingVersion{
+			OldUrl:    oldLongUrl,
+			NewUrl:    newLongUrl,
+			ChangedBy: updateRequest.UserId,
+			ChangedAt: now,
+		})
+	}
+
+	change.Before = &before
+	change.After = mapping
+
+	response := gin.H{
 		"message": "url updated successfully",
-	})
+	}
+	if mapping.ExpiresAt != nil {
+		response["expires_at"] = mapping.ExpiresAt
+	}
+	c.JSON(200, response)
 }
 
 func (h *handler) HandleShortUrlRedirect(c *gin.Context) {
 	shortUrl := c.Param("shortUrl")
-	initialUrl, err := h.store.RetrieveInitialUrl(c, shortUrl)
+	mapping, err := h.redirectStore.RetrieveUrlMapping(c, shortUrl)
 	if err != nil {
 		log.Err(err).Msg(fmt.Sprintf("Failed retrieving inital url | Error: %v - shortUrl: %s", err, shortUrl))
 		c.JSON(404, gin.H{
@@ -131,7 +432,71 @@ func (h *handler) HandleShortUrlRedirect(c *gin.Context) {
 		})
 		return
 	}
-	c.Redirect(302, initialUrl)
+
+	if mapping.IsDeleted() {
+		c.JSON(http.StatusGone, gin.H{
+			"message": "This short url has been removed.",
+		})
+		return
+	}
+
+	if mapping.IsExpired(time.Now()) {
+		c.JSON(http.StatusGone, gin.H{
+			"message": "This short url has expired.",
+		})
+		return
+	}
+
+	if err := h.checkDomain(mapping.OriginalUrl); err != nil {
+		log.Warn().Msg(fmt.Sprintf("Refused redirect to a blocked domain | shortUrl: %s - originalUrl: %s", shortUrl, mapping.OriginalUrl))
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "This short url points to a blocked domain.",
+		})
+		return
+	}
+
+	extraPath := c.Param("path")
+	if extraPath != "" && extraPath != "/" && !mapping.ForwardPath {
+		c.JSON(404, gin.H{
+			"message": "Something's wrong, i can feel it... Maybe you entered the wrong link.",
+		})
+		return
+	}
+
+	var incomingQuery url.Values
+	if mapping.ForwardQuery {
+		incomingQuery = c.Request.URL.Query()
+	}
+
+	destination, err := buildDestination(mapping, extraPath, incomingQuery)
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed building destination url | Error: %v - shortUrl: %s", err, shortUrl))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Redirect(h.redirectType(mapping), destination)
+}
+
+func (h *handler) publicShortUrl(shortUrl string) string {
+	return fmt.Sprintf("http://%s:%s/%s", h.cfg.ServerHost, h.cfg.ServerPort, shortUrl)
+}
+
+func (h *handler) checkDomain(longUrl string) error {
+	if h.domainPolicy == nil {
+		return nil
+	}
+	return h.domainPolicy.Check(longUrl)
+}
+
+func (h *handler) redirectType(mapping *store.UrlMapping) int {
+	if isValidRedirectType(mapping.RedirectType) {
+		return mapping.RedirectType
+	}
+	if isValidRedirectType(h.cfg.DefaultRedirectType) {
+		return h.cfg.DefaultRedirectType
+	}
+	return http.StatusFound
 }
 
 func (h *handler) RemoveShortUrl(c *gin.Context) {
@@ -140,20 +505,93 @@ func (h *handler) RemoveShortUrl(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	removeRequest.UserId = requestUserId(c, removeRequest.UserId)
+	change := audit.Describe(c)
+	change.Actor = removeRequest.UserId
+	change.ShortCode = removeRequest.ShortUrl
+
+	if removeRequest.UserId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input a valid user id!"})
+		return
+	}
 
 	if !h.store.CheckIfShortUrlExists(c, removeRequest.ShortUrl) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Short url doesn't exist!"})
 		return
 	}
 
-	err := h.store.DeleteUrlMapping(c, removeRequest.ShortUrl)
+	mapping, err := h.store.RetrieveUrlMapping(c, removeRequest.ShortUrl)
 	if err != nil {
-		log.Err(err).Msg(fmt.Sprintf("Failed deleting key url | Error: %v - shortUrl: %s", err, removeRequest.ShortUrl))
+		log.Err(err).Msg(fmt.Sprintf("Failed retrieving url mapping | Error: %v - shortUrl: %s", err, removeRequest.ShortUrl))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if !mapping.IsOwnedBy(removeRequest.UserId) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the owner of this short url!"})
+		return
+	}
+
+	if mapping.IsDeleted() {
+		c.JSON(http.StatusGone, gin.H{"error": "Short url is already removed!"})
+		return
+	}
+
+	before := *mapping
+	now := time.Now().UTC()
+	mapping.DeletedAt = &now
+
+	err = h.store.SaveUrlMapping(c, removeRequest.ShortUrl, *mapping)
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed removing key url | Error: %v - shortUrl: %s", err, removeRequest.ShortUrl))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	change.Before = &before
+	change.After = mapping
+
 	c.JSON(200, gin.H{
-		"message": "short url deleted successfully",
+		"message":          "short url deleted successfully",
+		"restorable_until": now.Add(h.deletedUrlRetention()),
 	})
 }
+
+// requestUserId gives the authenticated principal, which takes the place of
+// the user_id in the request body. The body is only trusted when
+// authentication is disabled.
+func requestUserId(c *gin.Context, userId string) string {
+	if principal, ok := auth.Principal(c); ok {
+		return principal
+	}
+	return userId
+}
+
+func resolveExpiry(expiresAt *time.Time, ttlSeconds int64, now time.Time) (*time.Time, error) {
+	if expiresAt != nil && ttlSeconds != 0 {
+		return nil, errors.New("Please input either expires_at or ttl_seconds, not both!")
+	}
+
+	if ttlSeconds < 0 || ttlSeconds > maxTtlSeconds {
+		return nil, errors.New("Please input a valid ttl_seconds!")
+	}
+
+	if ttlSeconds > 0 {
+		expiry := now.Add(time.Duration(ttlSeconds) * time.Second).UTC()
+		return &expiry, nil
+	}
+
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, errors.New("Please input an expires_at in the future!")
+	}
+
+	return expiresAt, nil
+}
+
+func isValidRedirectType(redirectType int) bool {
+	switch redirectType {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
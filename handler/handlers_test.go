@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -15,8 +18,11 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/audit"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
 	"source.golabs.io/daniel.santoso/url-blaster/config"
 	"source.golabs.io/daniel.santoso/url-blaster/handler"
+	"source.golabs.io/daniel.santoso/url-blaster/policy"
 	"source.golabs.io/daniel.santoso/url-blaster/shortener"
 	"source.golabs.io/daniel.santoso/url-blaster/store"
 )
@@ -24,6 +30,12 @@ import (
 const UserId = "e0dba740-fc4b-4977-872c-d360239e6b1a"
 const CacheDuration = 6 * time.Hour
 
+func MockOwnedUrlMapping(t *testing.T, initialUrl string) string {
+	value, err := json.Marshal(store.UrlMapping{OriginalUrl: initialUrl, Owner: UserId})
+	assert.NoError(t, err)
+	return string(value)
+}
+
 func MockCreationJSONPost(c *gin.Context, urlCreationRequest handler.UrlCreationRequest) {
 	c.Request.Method = "POST"
 	c.Request.Header.Set("Content-Type", "application/json")
@@ -254,7 +266,7 @@ func TestUpdateUrlSuccess(t *testing.T) {
 
 	shortUrl := "dyna"
 	initialUrl := "https://youtu.be/8LhMu4bQTQU"
-	redisClient.Set(ctx, shortUrl, initialUrl, CacheDuration)
+	redisClient.Set(ctx, shortUrl, MockOwnedUrlMapping(t, initialUrl), CacheDuration)
 
 	storageService := store.StorageService{
 		RedisClient: redisClient,
@@ -270,6 +282,7 @@ func TestUpdateUrlSuccess(t *testing.T) {
 	MockUpdateJSONPost(c, handler.UrlUpdateRequest{
 		ShortUrl:   "dyna",
 		NewLongUrl: "https://youtu.be/UIbNIhaldLQ",
+		UserId:     UserId,
 	})
 
 	h.UpdateLongUrl(c)
@@ -289,7 +302,7 @@ func TestUpdateUrlEmptyShortUrl(t *testing.T) {
 
 	shortUrl := "dyna"
 	initialUrl := "https://youtu.be/8LhMu4bQTQU"
-	redisClient.Set(ctx, shortUrl, initialUrl, CacheDuration)
+	redisClient.Set(ctx, shortUrl, MockOwnedUrlMapping(t, initialUrl), CacheDuration)
 
 	storageService := store.StorageService{
 		RedisClient: redisClient,
@@ -305,6 +318,7 @@ func TestUpdateUrlEmptyShortUrl(t *testing.T) {
 	MockUpdateJSONPost(c, handler.UrlUpdateRequest{
 		ShortUrl:   "",
 		NewLongUrl: "https://youtu.be/UIbNIhaldLQ",
+		UserId:     UserId,
 	})
 
 	h.UpdateLongUrl(c)
@@ -324,7 +338,7 @@ func TestUpdateUrlInvalidLink(t *testing.T) {
 
 	shortUrl := "dyna"
 	initialUrl := "https://youtu.be/8LhMu4bQTQU"
-	redisClient.Set(ctx, shortUrl, initialUrl, CacheDuration)
+	redisClient.Set(ctx, shortUrl, MockOwnedUrlMapping(t, initialUrl), CacheDuration)
 
 	storageService := store.StorageService{
 		RedisClient: redisClient,
@@ -340,6 +354,7 @@ func TestUpdateUrlInvalidLink(t *testing.T) {
 	MockUpdateJSONPost(c, handler.UrlUpdateRequest{
 		ShortUrl:   "dyna",
 		NewLongUrl: "hahahaha",
+		UserId:     UserId,
 	})
 
 	h.UpdateLongUrl(c)
@@ -359,7 +374,7 @@ func TestUpdateUrlRedisFail(t *testing.T) {
 
 	shortUrl := "dyna"
 	initialUrl := "https://youtu.be/8LhMu4bQTQU"
-	redisClient.Set(ctx, shortUrl, initialUrl, CacheDuration)
+	redisClient.Set(ctx, shortUrl, MockOwnedUrlMapping(t, initialUrl), CacheDuration)
 
 	storageService := store.StorageService{
 		RedisClient: redisClient,
@@ -375,6 +390,7 @@ func TestUpdateUrlRedisFail(t *testing.T) {
 	MockUpdateJSONPost(c, handler.UrlUpdateRequest{
 		ShortUrl:   "dyna",
 		NewLongUrl: "https://youtu.be/UIbNIhaldLQ",
+		UserId:     UserId,
 	})
 
 	redisServer.SetError("REDISDOWN")
@@ -395,7 +411,7 @@ func TestUpdateUrlUnavailableShortLink(t *testing.T) {
 
 	shortUrl := "dyna"
 	initialUrl := "https://youtu.be/8LhMu4bQTQU"
-	redisClient.Set(ctx, shortUrl, initialUrl, CacheDuration)
+	redisClient.Set(ctx, shortUrl, MockOwnedUrlMapping(t, initialUrl), CacheDuration)
 
 	storageService := store.StorageService{
 		RedisClient: redisClient,
@@ -411,6 +427,7 @@ func TestUpdateUrlUnavailableShortLink(t *testing.T) {
 	MockUpdateJSONPost(c, handler.UrlUpdateRequest{
 		ShortUrl:   "gaia",
 		NewLongUrl: "https://youtu.be/UIbNIhaldLQ",
+		UserId:     UserId,
 	})
 
 	h.UpdateLongUrl(c)
@@ -495,7 +512,7 @@ func TestRemoveUrlSuccess(t *testing.T) {
 
 	shortUrl := "dyna"
 	initialUrl := "https://youtu.be/8LhMu4bQTQU"
-	redisClient.Set(ctx, shortUrl, initialUrl, CacheDuration)
+	redisClient.Set(ctx, shortUrl, MockOwnedUrlMapping(t, initialUrl), CacheDuration)
 
 	storageService := store.StorageService{
 		RedisClient: redisClient,
@@ -510,6 +527,7 @@ func TestRemoveUrlSuccess(t *testing.T) {
 
 	MockRemoveJSONPost(c, handler.UrlRemoveRequest{
 		ShortUrl: "dyna",
+		UserId:   UserId,
 	})
 
 	h.RemoveShortUrl(c)
@@ -529,7 +547,7 @@ func TestRemoveUrlEmptyShortUrl(t *testing.T) {
 
 	shortUrl := "dyna"
 	initialUrl := "https://youtu.be/8LhMu4bQTQU"
-	redisClient.Set(ctx, shortUrl, initialUrl, CacheDuration)
+	redisClient.Set(ctx, shortUrl, MockOwnedUrlMapping(t, initialUrl), CacheDuration)
 
 	storageService := store.StorageService{
 		RedisClient: redisClient,
@@ -544,6 +562,7 @@ func TestRemoveUrlEmptyShortUrl(t *testing.T) {
 
 	MockRemoveJSONPost(c, handler.UrlRemoveRequest{
 		ShortUrl: "",
+		UserId:   UserId,
 	})
 
 	h.RemoveShortUrl(c)
@@ -563,7 +582,7 @@ func TestRemoveUrlRedisFail(t *testing.T) {
 
 	shortUrl := "dyna"
 	initialUrl := "https://youtu.be/8LhMu4bQTQU"
-	redisClient.Set(ctx, shortUrl, initialUrl, CacheDuration)
+	redisClient.Set(ctx, shortUrl, MockOwnedUrlMapping(t, initialUrl), CacheDuration)
 
 	storageService := store.StorageService{
 		RedisClient: redisClient,
@@ -578,6 +597,7 @@ func TestRemoveUrlRedisFail(t *testing.T) {
 
 	MockRemoveJSONPost(c, handler.UrlRemoveRequest{
 		ShortUrl: "dyna",
+		UserId:   UserId,
 	})
 
 	redisServer.SetError("REDISDOWN")
@@ -598,7 +618,7 @@ func TestRemoveUrlUnavailableShortLink(t *testing.T) {
 
 	shortUrl := "dyna"
 	initialUrl := "https://youtu.be/8LhMu4bQTQU"
-	redisClient.Set(ctx, shortUrl, initialUrl, CacheDuration)
+	redisClient.Set(ctx, shortUrl, MockOwnedUrlMapping(t, initialUrl), CacheDuration)
 
 	storageService := store.StorageService{
 		RedisClient: redisClient,
@@ -613,9 +633,918 @@ func TestRemoveUrlUnavailableShortLink(t *testing.T) {
 
 	MockRemoveJSONPost(c, handler.UrlRemoveRequest{
 		ShortUrl: "gaia",
+		UserId:   UserId,
 	})
 
 	h.RemoveShortUrl(c)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
+
+func TestCreateShortUrlWithTtlSuccess(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+
+	MockCreationJSONPost(c, handler.UrlCreationRequest{
+		LongUrl:        "https://youtu.be/8LhMu4bQTQU",
+		UserId:         "e0dba740-fc4b-4977-872c-d360239e6b10",
+		PredefinedName: "dyna",
+		TtlSeconds:     3600,
+	})
+
+	h.CreateShortUrl(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "expires_at")
+
+	mapping, err := storageService.RetrieveUrlMapping(context.TODO(), "dyna")
+	assert.NoError(t, err)
+	assert.NotNil(t, mapping.ExpiresAt)
+	assert.Equal(t, "e0dba740-fc4b-4977-872c-d360239e6b10", mapping.Owner)
+}
+
+func TestCreateShortUrlWithExpiresAtAndTtl(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	MockCreationJSONPost(c, handler.UrlCreationRequest{
+		LongUrl:    "https://youtu.be/8LhMu4bQTQU",
+		UserId:     "e0dba740-fc4b-4977-872c-d360239e6b10",
+		ExpiresAt:  &expiresAt,
+		TtlSeconds: 3600,
+	})
+
+	h.CreateShortUrl(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateShortUrlWithPastExpiresAt(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+
+	expiresAt := time.Now().Add(-time.Hour)
+	MockCreationJSONPost(c, handler.UrlCreationRequest{
+		LongUrl:   "https://youtu.be/8LhMu4bQTQU",
+		UserId:    "e0dba740-fc4b-4977-872c-d360239e6b10",
+		ExpiresAt: &expiresAt,
+	})
+
+	h.CreateShortUrl(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUpdateUrlKeepsExpiry(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	expiresAt := time.Now().Add(time.Hour)
+	err = storageService.SaveUrlMapping(ctx, "dyna", store.UrlMapping{
+		OriginalUrl: "https://youtu.be/8LhMu4bQTQU",
+		Owner:       UserId,
+		ExpiresAt:   &expiresAt,
+	})
+	assert.NoError(t, err)
+
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+
+	MockUpdateJSONPost(c, handler.UrlUpdateRequest{
+		ShortUrl:   "dyna",
+		NewLongUrl: "https://youtu.be/UIbNIhaldLQ",
+		UserId:     UserId,
+	})
+
+	h.UpdateLongUrl(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mapping, err := storageService.RetrieveUrlMapping(ctx, "dyna")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://youtu.be/UIbNIhaldLQ", mapping.OriginalUrl)
+	assert.True(t, expiresAt.Equal(*mapping.ExpiresAt))
+}
+
+func TestRedirectShortUrlExpired(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	shortUrl := "NpHftVNe"
+	redisClient.Set(ctx, shortUrl, `{"original_url":"https://www.youtube.com/watch?v=dQw4w9WgXcQ","expires_at":"2022-01-01T00:00:00Z"}`, CacheDuration)
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+	c.AddParam("shortUrl", shortUrl)
+
+	h.HandleShortUrlRedirect(c)
+
+	assert.Equal(t, http.StatusGone, w.Code)
+}
+
+func TestCreateShortUrlWithTakenPredefinedName(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	initialUrl := "https://youtu.be/8LhMu4bQTQU"
+	redisClient.Set(ctx, "dyna", initialUrl, CacheDuration)
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+
+	MockCreationJSONPost(c, handler.UrlCreationRequest{
+		LongUrl:        "https://youtu.be/UIbNIhaldLQ",
+		UserId:         "e0dba740-fc4b-4977-872c-d360239e6b10",
+		PredefinedName: "dyna",
+	})
+
+	h.CreateShortUrl(c)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	retrievedUrl, err := storageService.RetrieveInitialUrl(ctx, "dyna")
+	assert.NoError(t, err)
+	assert.Equal(t, initialUrl, retrievedUrl)
+}
+
+func TestCreateShortUrlRegeneratesOnCollision(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	longUrl := "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+	collidingShortUrl, err := shortener.GenerateShortLink(longUrl, UserId)
+	assert.NoError(t, err)
+	redisClient.Set(ctx, collidingShortUrl, "https://youtu.be/8LhMu4bQTQU", CacheDuration)
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+
+	MockCreationJSONPost(c, handler.UrlCreationRequest{
+		LongUrl: longUrl,
+		UserId:  UserId,
+	})
+
+	h.CreateShortUrl(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), collidingShortUrl)
+
+	retrievedUrl, err := storageService.RetrieveInitialUrl(ctx, collidingShortUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://youtu.be/8LhMu4bQTQU", retrievedUrl)
+}
+
+func TestCreateShortUrlTwiceReusesShortUrl(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+
+	var bodies []string
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		c.Request = &http.Request{
+			Header: make(http.Header),
+		}
+
+		MockCreationJSONPost(c, handler.UrlCreationRequest{
+			LongUrl: "https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+			UserId:  UserId,
+		})
+
+		h.CreateShortUrl(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		bodies = append(bodies, w.Body.String())
+	}
+
+	assert.Equal(t, bodies[0], bodies[1])
+}
+
+func TestUpdateUrlNotOwner(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	shortUrl := "dyna"
+	initialUrl := "https://youtu.be/8LhMu4bQTQU"
+	redisClient.Set(ctx, shortUrl, MockOwnedUrlMapping(t, initialUrl), CacheDuration)
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+
+	MockUpdateJSONPost(c, handler.UrlUpdateRequest{
+		ShortUrl:   "dyna",
+		NewLongUrl: "https://youtu.be/UIbNIhaldLQ",
+		UserId:     "e0dba740-fc4b-4977-872c-d360239e6b10",
+	})
+
+	h.UpdateLongUrl(c)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	retrievedUrl, err := storageService.RetrieveInitialUrl(ctx, shortUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, initialUrl, retrievedUrl)
+}
+
+func TestUpdateUrlEmptyUserId(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	shortUrl := "dyna"
+	initialUrl := "https://youtu.be/8LhMu4bQTQU"
+	redisClient.Set(ctx, shortUrl, MockOwnedUrlMapping(t, initialUrl), CacheDuration)
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+
+	MockUpdateJSONPost(c, handler.UrlUpdateRequest{
+		ShortUrl:   "dyna",
+		NewLongUrl: "https://youtu.be/UIbNIhaldLQ",
+	})
+
+	h.UpdateLongUrl(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRemoveUrlNotOwner(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	shortUrl := "dyna"
+	initialUrl := "https://youtu.be/8LhMu4bQTQU"
+	redisClient.Set(ctx, shortUrl, MockOwnedUrlMapping(t, initialUrl), CacheDuration)
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+
+	MockRemoveJSONPost(c, handler.UrlRemoveRequest{
+		ShortUrl: "dyna",
+		UserId:   "e0dba740-fc4b-4977-872c-d360239e6b10",
+	})
+
+	h.RemoveShortUrl(c)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.True(t, storageService.CheckIfShortUrlExists(ctx, shortUrl))
+}
+
+func TestRemoveUrlWithoutRecordedOwner(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	shortUrl := "dyna"
+	initialUrl := "https://youtu.be/8LhMu4bQTQU"
+	redisClient.Set(ctx, shortUrl, initialUrl, CacheDuration)
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+
+	MockRemoveJSONPost(c, handler.UrlRemoveRequest{
+		ShortUrl: "dyna",
+		UserId:   UserId,
+	})
+
+	h.RemoveShortUrl(c)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestRedirectShortUrlWithRedirectType(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	shortUrl := "NpHftVNe"
+	initialUrl := "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	err = storageService.SaveUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: initialUrl, RedirectType: http.StatusPermanentRedirect})
+	assert.NoError(t, err)
+
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+	c.AddParam("shortUrl", shortUrl)
+
+	h.HandleShortUrlRedirect(c)
+
+	assert.Equal(t, http.StatusPermanentRedirect, c.Writer.Status())
+	assert.Equal(t, initialUrl, w.Header().Get("Location"))
+}
+
+func TestRedirectShortUrlWithDefaultRedirectType(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	cfg.DefaultRedirectType = http.StatusMovedPermanently
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	shortUrl := "NpHftVNe"
+	initialUrl := "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+	redisClient.Set(ctx, shortUrl, initialUrl, CacheDuration)
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+	c.AddParam("shortUrl", shortUrl)
+
+	h.HandleShortUrlRedirect(c)
+
+	assert.Equal(t, http.StatusMovedPermanently, c.Writer.Status())
+}
+
+func TestCreateShortUrlWithInvalidRedirectType(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+
+	MockCreationJSONPost(c, handler.UrlCreationRequest{
+		LongUrl:      "https://youtu.be/8LhMu4bQTQU",
+		UserId:       "e0dba740-fc4b-4977-872c-d360239e6b10",
+		RedirectType: http.StatusSeeOther,
+	})
+
+	h.CreateShortUrl(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUpdateUrlRedirectType(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	shortUrl := "dyna"
+	initialUrl := "https://youtu.be/8LhMu4bQTQU"
+	redisClient.Set(ctx, shortUrl, MockOwnedUrlMapping(t, initialUrl), CacheDuration)
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+
+	MockUpdateJSONPost(c, handler.UrlUpdateRequest{
+		ShortUrl:     "dyna",
+		NewLongUrl:   "https://youtu.be/UIbNIhaldLQ",
+		UserId:       UserId,
+		RedirectType: http.StatusTemporaryRedirect,
+	})
+
+	h.UpdateLongUrl(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mapping, err := storageService.RetrieveUrlMapping(ctx, shortUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTemporaryRedirect, mapping.RedirectType)
+}
+
+func TestRedirectShortUrlWithPassthrough(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	err = storageService.SaveUrlMapping(ctx, "docs", store.UrlMapping{
+		OriginalUrl:  "https://docs.example.com/v2?lang=en",
+		ForwardQuery: true,
+		ForwardPath:  true,
+	})
+	assert.NoError(t, err)
+
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	router := gin.New()
+	router.GET("/:shortUrl", h.HandleShortUrlRedirect)
+	router.GET("/:shortUrl/*path", h.HandleShortUrlRedirect)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs/guide/setup?utm_source=x", nil))
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://docs.example.com/v2/guide/setup?lang=en&utm_source=x", w.Header().Get("Location"))
+}
+
+func TestRedirectShortUrlWithExtraPathNotForwarded(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	redisClient.Set(ctx, "docs", "https://docs.example.com/v2?lang=en", CacheDuration)
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	router := gin.New()
+	router.GET("/:shortUrl", h.HandleShortUrlRedirect)
+	router.GET("/:shortUrl/*path", h.HandleShortUrlRedirect)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs/guide?utm_source=x", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs?utm_source=x", nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://docs.example.com/v2?lang=en", w.Header().Get("Location"))
+}
+
+func TestCreateShortUrlWithInvalidQueryPrecedence(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+
+	MockCreationJSONPost(c, handler.UrlCreationRequest{
+		LongUrl:         "https://youtu.be/8LhMu4bQTQU",
+		UserId:          "e0dba740-fc4b-4977-872c-d360239e6b10",
+		ForwardQuery:    true,
+		QueryPrecedence: "random",
+	})
+
+	h.CreateShortUrl(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateShortUrlNormalizesEquivalentLinks(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+
+	var shortUrls []string
+	for _, longUrl := range []string{"http://Example.COM:80", "http://example.com/"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		c.Request = &http.Request{
+			Header: make(http.Header),
+		}
+
+		MockCreationJSONPost(c, handler.UrlCreationRequest{
+			LongUrl: longUrl,
+			UserId:  UserId,
+		})
+
+		h.CreateShortUrl(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string]string
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		shortUrls = append(shortUrls, response["short_url"])
+	}
+
+	expectedShortUrl, err := shortener.GenerateShortLink("http://example.com/", UserId)
+	assert.NoError(t, err)
+	assert.Equal(t, shortUrls[0], shortUrls[1])
+	assert.Equal(t, fmt.Sprintf("http://%s:%s/%s", cfg.ServerHost, cfg.ServerPort, expectedShortUrl), shortUrls[0])
+}
+
+func TestUpdateLongUrlWithUnsupportedScheme(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	redisClient.Set(ctx, "ZdWyN3Nr", MockOwnedUrlMapping(t, "https://youtu.be/8LhMu4bQTQU"), CacheDuration)
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+
+	MockUpdateJSONPost(c, handler.UrlUpdateRequest{
+		ShortUrl:   "ZdWyN3Nr",
+		NewLongUrl: "ftp://youtu.be/UIbNIhaldLQ",
+		UserId:     UserId,
+	})
+
+	h.UpdateLongUrl(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "allowed scheme")
+}
+
+func TestCreateShortUrlWithBlockedDomain(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+
+	policyFile := filepath.Join(t.TempDir(), "domain-policy.yml")
+	assert.NoError(t, os.WriteFile(policyFile, []byte("blocklist:\n  - \"*.phish.example\"\n"), 0o644))
+	domainPolicy, err := policy.NewDomainPolicy(policyFile)
+	assert.NoError(t, err)
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService, handler.WithDomainPolicy(domainPolicy))
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+
+	MockCreationJSONPost(c, handler.UrlCreationRequest{
+		LongUrl: "https://login.phish.example/bank",
+		UserId:  UserId,
+	})
+
+	h.CreateShortUrl(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Len(t, redisServer.Keys(), 0)
+}
+
+func TestHandleShortUrlRedirectToNewlyBlockedDomain(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	redisClient.Set(ctx, "ZdWyN3Nr", MockOwnedUrlMapping(t, "https://youtu.be/8LhMu4bQTQU"), CacheDuration)
+
+	policyFile := filepath.Join(t.TempDir(), "domain-policy.yml")
+	assert.NoError(t, os.WriteFile(policyFile, []byte("blocklist: []\n"), 0o644))
+	domainPolicy, err := policy.NewDomainPolicy(policyFile)
+	assert.NoError(t, err)
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService, handler.WithDomainPolicy(domainPolicy))
+	router := gin.New()
+	router.GET("/:shortUrl", h.HandleShortUrlRedirect)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ZdWyN3Nr", nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	assert.NoError(t, os.WriteFile(policyFile, []byte("blocklist:\n  - youtu.be\n"), 0o644))
+	assert.NoError(t, domainPolicy.Reload())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ZdWyN3Nr", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+}
+
+func TestCreateShortUrlOwnedByAuthenticatedPrincipal(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+	auth.SetPrincipal(c, "api-key-principal")
+
+	MockCreationJSONPost(c, handler.UrlCreationRequest{
+		LongUrl:        "https://youtu.be/8LhMu4bQTQU",
+		UserId:         UserId,
+		PredefinedName: "principal",
+	})
+
+	h.CreateShortUrl(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mapping, err := storageService.RetrieveUrlMapping(context.TODO(), "principal")
+	assert.NoError(t, err)
+	assert.Equal(t, "api-key-principal", mapping.Owner)
+}
+
+func TestUpdateUrlIsAudited(t *testing.T) {
+	storageService := store.NewMemoryStorageService()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	h := handler.NewHandler(shortener.NewShortener(), cfg, storageService)
+	sink := audit.NewMemorySink()
+
+	router := gin.New()
+	router.POST("/create-short-url", audit.Track(sink, audit.ActionCreate), h.CreateShortUrl)
+	router.POST("/update-url", audit.Track(sink, audit.ActionUpdate), h.UpdateLongUrl)
+
+	w := MockJSONPost(router, "/create-short-url", handler.UrlCreationRequest{
+		LongUrl:        "https://ultra.fandom.com/wiki/Ultraman_Tiga",
+		PredefinedName: "tiga",
+		UserId:         UserId,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = MockJSONPost(router, "/update-url", handler.UrlUpdateRequest{
+		ShortUrl:   "tiga",
+		NewLongUrl: "https://ultra.fandom.com/wiki/Ultraman_Dyna",
+		UserId:     "someone-else",
+	})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = MockJSONPost(router, "/update-url", handler.UrlUpdateRequest{
+		ShortUrl:   "tiga",
+		NewLongUrl: "https://ultra.fandom.com/wiki/Ultraman_Dyna",
+		UserId:     UserId,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	entries, err := sink.Query(context.TODO(), audit.Filter{ShortCode: "tiga"})
+	assert.NoError(t, err)
+	if !assert.Len(t, entries, 3) {
+		return
+	}
+
+	assert.Equal(t, audit.ActionUpdate, entries[0].Action)
+	assert.Equal(t, UserId, entries[0].Actor)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Tiga", entries[0].Before.OriginalUrl)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Dyna", entries[0].After.OriginalUrl)
+
+	assert.Equal(t, "someone-else", entries[1].Actor)
+	assert.Equal(t, audit.OutcomeRejected, entries[1].Outcome)
+	assert.Nil(t, entries[1].Before)
+
+	assert.Equal(t, audit.ActionCreate, entries[2].Action)
+	assert.Nil(t, entries[2].Before)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Tiga", entries[2].After.OriginalUrl)
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"source.golabs.io/daniel.santoso/url-blaster/audit"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+type LinkRollbackRequest struct {
+	Version int64  `json:"version" binding:"required"`
+	UserId  string `json:"user_id"`
+}
+
+// GetLinkHistory lists the changes of the long url of a short url, newest
+// first.
+func (h *handler) GetLinkHistory(c *gin.Context) {
+	shortUrl := c.Param("shortUrl")
+
+	mapping, err := h.store.RetrieveUrlMapping(c, shortUrl)
+	if errors.Is(err, store.ErrUrlNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Short url doesn't exist!"})
+		return
+	}
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed retrieving url mapping | Error: %v - shortUrl: %s", err, shortUrl))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if principal, ok := auth.Principal(c); ok && !mapping.IsOwnedBy(principal) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the owner of this short url!"})
+		return
+	}
+
+	versions, err := h.store.ListUrlMappingVersions(c, shortUrl)
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed listing url mapping versions | Error: %v - shortUrl: %s", err, shortUrl))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"short_code": shortUrl,
+		"versions":   versions,
+	})
+}
+
+// RollbackLink points a short url back to the long url it had at a version of
+// its history. The rollback is recorded as a new version.
+func (h *handler) RollbackLink(c *gin.Context) {
+	shortUrl := c.Param("shortUrl")
+
+	var rollbackRequest LinkRollbackRequest
+	if err := c.ShouldBindJSON(&rollbackRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rollbackRequest.UserId = requestUserId(c, rollbackRequest.UserId)
+	change := audit.Describe(c)
+	change.Actor = rollbackRequest.UserId
+	change.ShortCode = shortUrl
+
+	if rollbackRequest.UserId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input a valid user id!"})
+		return
+	}
+
+	mapping, err := h.store.RetrieveUrlMapping(c, shortUrl)
+	if errors.Is(err, store.ErrUrlNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Short url doesn't exist!"})
+		return
+	}
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed retrieving url mapping | Error: %v - shortUrl: %s", err, shortUrl))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !mapping.IsOwnedBy(rollbackRequest.UserId) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the owner of this short url!"})
+		return
+	}
+
+	if mapping.IsDeleted() {
+		c.JSON(http.StatusGone, gin.H{"error": "Short url is removed, please restore it first!"})
+		return
+	}
+
+	versions, err := h.store.ListUrlMappingVersions(c, shortUrl)
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed listing url mapping versions | Error: %v - shortUrl: %s", err, shortUrl))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var target *store.UrlMappingVersion
+	for i := range versions {
+		if versions[i].Version == rollbackRequest.Version {
+			target = &versions[i]
+			break
+		}
+	}
+	if target == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version doesn't exist!"})
+		return
+	}
+
+	if target.NewUrl == mapping.OriginalUrl {
+		c.JSON(200, gin.H{"message": "url is already at this version"})
+		return
+	}
+
+	if err := h.checkDomain(target.NewUrl); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.validateRedirectChain(c, shortUrl, target.NewUrl)
+	if isRedirectChainError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed resolving redirect chain | Error: %v - shortUrl: %s - originalUrl: %s", err, shortUrl, target.NewUrl))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := *mapping
+	now := time.Now().UTC()
+	oldLongUrl := mapping.OriginalUrl
+	mapping.OriginalUrl = target.NewUrl
+	mapping.UpdatedAt = &now
+
+	err = h.store.SaveUrlMapping(c, shortUrl, *mapping)
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed saving key url | Error: %v - shortUrl: %s - originalUrl: %s", err, shortUrl, target.NewUrl))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	change.Before = &before
+	change.After = mapping
+
+	version := h.recordVersion(c, shortUrl, store.UrlMappingVersion{
+		OldUrl:       oldLongUrl,
+		NewUrl:       target.NewUrl,
+		ChangedBy:    rollbackRequest.UserId,
+		ChangedAt:    now,
+		RolledBackTo: target.Version,
+	})
+
+	response := gin.H{
+		"message":  "url rolled back successfully",
+		"long_url": target.NewUrl,
+	}
+	if version != nil {
+		response["version"] = version.Version
+	}
+	c.JSON(200, response)
+}
+
+// recordVersion doesn't fail the request, the change itself is already saved
+// by the time it is recorded.
+func (h *handler) recordVersion(ctx context.Context, shortUrl string, version store.UrlMappingVersion) *store.UrlMappingVersion {
+	recorded, err := h.store.AddUrlMappingVersion(ctx, shortUrl, version)
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed recording url mapping version | Error: %v - shortUrl: %s - originalUrl: %s", err, shortUrl, version.NewUrl))
+		return nil
+	}
+	return recorded
+}
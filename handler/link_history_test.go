@@ -0,0 +1,88 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/handler"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+type LinkHistoryResponse struct {
+	ShortCode string                    `json:"short_code"`
+	Versions  []store.UrlMappingVersion `json:"versions"`
+}
+
+func MockGetLinkHistory(t *testing.T, router *gin.Engine, shortUrl string) (int, LinkHistoryResponse) {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/links/"+shortUrl+"/history", nil))
+
+	var response LinkHistoryResponse
+	if w.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	}
+	return w.Code, response
+}
+
+func TestLinkHistoryAndRollback(t *testing.T) {
+	router := MockLinksRouter(t, store.NewMemoryStorageService(), UserId)
+
+	w := MockJSONPost(router, "/create-short-url", handler.UrlCreationRequest{
+		LongUrl:        "https://ultra.fandom.com/wiki/Ultraman_Cosmos",
+		PredefinedName: "cosmos",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	for _, newLongUrl := range []string{
+		"https://ultra.fandom.com/wiki/Ultraman_Cosmos_(character)",
+		"https://ultra.fandom.com/wiki/Ultraman_Cosmos_(series)",
+	} {
+		w = MockJSONPost(router, "/update-url", handler.UrlUpdateRequest{ShortUrl: "cosmos", NewLongUrl: newLongUrl})
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	code, history := MockGetLinkHistory(t, router, "cosmos")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, history.Versions, 3)
+	assert.Equal(t, int64(3), history.Versions[0].Version)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Cosmos_(character)", history.Versions[0].OldUrl)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Cosmos_(series)", history.Versions[0].NewUrl)
+	assert.Equal(t, UserId, history.Versions[0].ChangedBy)
+	assert.Equal(t, int64(1), history.Versions[2].Version)
+	assert.Empty(t, history.Versions[2].OldUrl)
+
+	w = MockJSONPost(router, "/links/cosmos/rollback", handler.LinkRollbackRequest{Version: 1})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	code, details := MockGetLink(t, router, "cosmos")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Cosmos", details.LongUrl)
+
+	_, history = MockGetLinkHistory(t, router, "cosmos")
+	assert.Len(t, history.Versions, 4)
+	assert.Equal(t, int64(1), history.Versions[0].RolledBackTo)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Cosmos_(series)", history.Versions[0].OldUrl)
+
+	w = MockJSONPost(router, "/links/cosmos/rollback", handler.LinkRollbackRequest{Version: 42})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestLinkHistoryNotOwner(t *testing.T) {
+	storageService := store.NewMemoryStorageService()
+	router := MockLinksRouter(t, storageService, UserId)
+	w := MockJSONPost(router, "/create-short-url", handler.UrlCreationRequest{
+		LongUrl:        "https://ultra.fandom.com/wiki/Ultraman_Cosmos",
+		PredefinedName: "cosmos",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	otherRouter := MockLinksRouter(t, storageService, "someone-else")
+	code, _ := MockGetLinkHistory(t, otherRouter, "cosmos")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	w = MockJSONPost(otherRouter, "/links/cosmos/rollback", handler.LinkRollbackRequest{Version: 1})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
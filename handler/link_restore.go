@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"source.golabs.io/daniel.santoso/url-blaster/audit"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+type LinkRestoreRequest struct {
+	UserId string `json:"user_id"`
+}
+
+// RestoreLink brings back a removed short url, as long as its retention is
+// not over. Past the retention the reaper purges it for good.
+func (h *handler) RestoreLink(c *gin.Context) {
+	shortUrl := c.Param("shortUrl")
+
+	var restoreRequest LinkRestoreRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&restoreRequest); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	restoreRequest.UserId = requestUserId(c, restoreRequest.UserId)
+	change := audit.Describe(c)
+	change.Actor = restoreRequest.UserId
+	change.ShortCode = shortUrl
+
+	if restoreRequest.UserId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input a valid user id!"})
+		return
+	}
+
+	mapping, err := h.store.RetrieveUrlMapping(c, shortUrl)
+	if errors.Is(err, store.ErrUrlNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Short url doesn't exist!"})
+		return
+	}
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed retrieving url mapping | Error: %v - shortUrl: %s", err, shortUrl))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !mapping.IsOwnedBy(restoreRequest.UserId) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the owner of this short url!"})
+		return
+	}
+
+	if !mapping.IsDeleted() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Short url is not removed!"})
+		return
+	}
+
+	if time.Now().After(mapping.DeletedAt.Add(h.deletedUrlRetention())) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Short url doesn't exist!"})
+		return
+	}
+
+	before := *mapping
+	now := time.Now().UTC()
+	mapping.DeletedAt = nil
+	mapping.UpdatedAt = &now
+
+	err = h.store.SaveUrlMapping(c, shortUrl, *mapping)
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed restoring key url | Error: %v - shortUrl: %s", err, shortUrl))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	change.Before = &before
+	change.After = mapping
+
+	c.JSON(200, gin.H{
+		"message":  "short url restored successfully",
+		"long_url": mapping.OriginalUrl,
+	})
+}
+
+func (h *handler) deletedUrlRetention() time.Duration {
+	if h.cfg.DeletedUrlRetentionSeconds > 0 {
+		return time.Duration(h.cfg.DeletedUrlRetentionSeconds) * time.Second
+	}
+	return store.DefaultDeletedUrlRetention
+}
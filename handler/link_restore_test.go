@@ -0,0 +1,106 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/handler"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func TestRemoveAndRestoreLink(t *testing.T) {
+	storageService := store.NewMemoryStorageService()
+	router := MockLinksRouter(t, storageService, UserId)
+	w := MockJSONPost(router, "/create-short-url", handler.UrlCreationRequest{
+		LongUrl:        "https://ultra.fandom.com/wiki/Ultraman_Tiga",
+		PredefinedName: "tiga",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = MockJSONPost(router, "/remove-url", handler.UrlRemoveRequest{ShortUrl: "tiga"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var removed struct {
+		RestorableUntil time.Time `json:"restorable_until"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &removed))
+	assert.WithinDuration(t, time.Now().Add(store.DefaultDeletedUrlRetention), removed.RestorableUntil, time.Minute)
+
+	redirect := httptest.NewRecorder()
+	router.ServeHTTP(redirect, httptest.NewRequest(http.MethodGet, "/tiga", nil))
+	assert.Equal(t, http.StatusGone, redirect.Code)
+	assert.Contains(t, redirect.Body.String(), "This short url has been removed.")
+
+	code, details := MockGetLink(t, router, "tiga")
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotNil(t, details.DeletedAt)
+
+	w = MockJSONPost(router, "/update-url", handler.UrlUpdateRequest{ShortUrl: "tiga", NewLongUrl: "https://ultra.fandom.com/wiki/Ultraman_Dyna"})
+	assert.Equal(t, http.StatusGone, w.Code)
+	w = MockJSONPost(router, "/remove-url", handler.UrlRemoveRequest{ShortUrl: "tiga"})
+	assert.Equal(t, http.StatusGone, w.Code)
+
+	w = MockJSONPost(MockLinksRouter(t, storageService, "someone-else"), "/links/tiga/restore", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = MockJSONPost(router, "/links/tiga/restore", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "short url restored successfully")
+
+	redirect = httptest.NewRecorder()
+	router.ServeHTTP(redirect, httptest.NewRequest(http.MethodGet, "/tiga", nil))
+	assert.Equal(t, http.StatusFound, redirect.Code)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Tiga", redirect.Header().Get("Location"))
+
+	w = MockJSONPost(router, "/links/tiga/restore", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestRestoreLinkAfterRetention(t *testing.T) {
+	storageService := store.NewMemoryStorageService()
+	removedAt := time.Now().Add(-store.DefaultDeletedUrlRetention - time.Hour)
+	assert.NoError(t, storageService.SaveUrlMapping(context.TODO(), "tiga", store.UrlMapping{
+		OriginalUrl: "https://ultra.fandom.com/wiki/Ultraman_Tiga",
+		Owner:       UserId,
+		DeletedAt:   &removedAt,
+	}))
+
+	w := MockJSONPost(MockLinksRouter(t, storageService, UserId), "/links/tiga/restore", nil)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCreateAgainDoesNotRestoreRemovedLink(t *testing.T) {
+	storageService := store.NewMemoryStorageService()
+	router := MockLinksRouter(t, storageService, UserId)
+	create := func(title string) string {
+		w := MockJSONPost(router, "/create-short-url", handler.UrlCreationRequest{
+			LongUrl: "https://ultra.fandom.com/wiki/Ultraman_Tiga",
+			Title:   title,
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+		var created struct {
+			ShortUrl string `json:"short_url"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		return created.ShortUrl[strings.LastIndex(created.ShortUrl, "/")+1:]
+	}
+
+	first := create("Ultraman Tiga")
+	assert.Equal(t, first, create("Another title"))
+	_, details := MockGetLink(t, router, first)
+	assert.Equal(t, "Ultraman Tiga", details.Title)
+
+	w := MockJSONPost(router, "/remove-url", handler.UrlRemoveRequest{ShortUrl: first})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	second := create("Ultraman Tiga")
+	assert.NotEqual(t, first, second)
+	_, details = MockGetLink(t, router, first)
+	assert.NotNil(t, details.DeletedAt)
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+const (
+	maxTitleLength = 200
+	maxTags        = 20
+	maxTagLength   = 50
+)
+
+type LinkDetails struct {
+	ShortCode string     `json:"short_code"`
+	ShortUrl  string     `json:"short_url"`
+	LongUrl   string     `json:"long_url"`
+	Owner     string     `json:"owner,omitempty"`
+	Title     string     `json:"title,omitempty"`
+	Tags      []string   `json:"tags"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Expired   bool       `json:"expired"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	RedirectType    int    `json:"redirect_type"`
+	ForwardQuery    bool   `json:"forward_query"`
+	ForwardPath     bool   `json:"forward_path"`
+	QueryPrecedence string `json:"query_precedence"`
+}
+
+// GetLink returns the record stored for a short url. Links written before
+// owners were recorded have no owner and can only be read with auth disabled.
+func (h *handler) GetLink(c *gin.Context) {
+	shortUrl := c.Param("shortUrl")
+
+	mapping, err := h.store.RetrieveUrlMapping(c, shortUrl)
+	if errors.Is(err, store.ErrUrlNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Short url doesn't exist!"})
+		return
+	}
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed retrieving url mapping | Error: %v - shortUrl: %s", err, shortUrl))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if principal, ok := auth.Principal(c); ok && !mapping.IsOwnedBy(principal) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the owner of this short url!"})
+		return
+	}
+
+	queryPrecedence := mapping.QueryPrecedence
+	if queryPrecedence == "" {
+		queryPrecedence = store.QueryPrecedenceIncoming
+	}
+	tags := mapping.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	c.JSON(200, LinkDetails{
+		ShortCode: shortUrl,
+		ShortUrl:  h.publicShortUrl(shortUrl),
+		LongUrl:   mapping.OriginalUrl,
+		Owner:     mapping.Owner,
+		Title:     mapping.Title,
+		Tags:      tags,
+		CreatedAt: mapping.CreatedAt,
+		UpdatedAt: mapping.UpdatedAt,
+		ExpiresAt: mapping.ExpiresAt,
+		Expired:   mapping.IsExpired(time.Now()),
+		DeletedAt: mapping.DeletedAt,
+
+		RedirectType:    h.redirectType(mapping),
+		ForwardQuery:    mapping.ForwardQuery,
+		ForwardPath:     mapping.ForwardPath,
+		QueryPrecedence: queryPrecedence,
+	})
+}
+
+func validateTitle(title string) error {
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return fmt.Errorf("Please input a title of at most %d characters!", maxTitleLength)
+	}
+	return nil
+}
+
+// normalizeTags trims and lowercases tags and drops duplicates, keeping the
+// order they were given in.
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) > maxTags {
+		return nil, fmt.Errorf("Please input at most %d tags!", maxTags)
+	}
+
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, fmt.Errorf("Please input tags of 1 to %d characters!", maxTagLength)
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	if len(normalized) == 0 {
+		return nil, nil
+	}
+	return normalized, nil
+}
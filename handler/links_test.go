@@ -0,0 +1,161 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
+	"source.golabs.io/daniel.santoso/url-blaster/config"
+	"source.golabs.io/daniel.santoso/url-blaster/handler"
+	"source.golabs.io/daniel.santoso/url-blaster/shortener"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func MockLinksRouter(t *testing.T, storageService store.StorageServiceI, principal string) *gin.Engine {
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+
+	h := handler.NewHandler(shortener.NewShortener(), cfg, storageService)
+	setPrincipal := func(c *gin.Context) {
+		if principal != "" {
+			auth.SetPrincipal(c, principal)
+		}
+	}
+	router := gin.New()
+	router.POST("/create-short-url", setPrincipal, h.CreateShortUrl)
+	router.POST("/bulk-create-short-url", setPrincipal, h.BulkCreateShortUrls)
+	router.POST("/update-url", setPrincipal, h.UpdateLongUrl)
+	router.GET("/links/:shortUrl", setPrincipal, h.GetLink)
+	router.GET("/links/:shortUrl/history", setPrincipal, h.GetLinkHistory)
+	router.POST("/links/:shortUrl/rollback", setPrincipal, h.RollbackLink)
+	router.POST("/links/:shortUrl/restore", setPrincipal, h.RestoreLink)
+	router.POST("/remove-url", setPrincipal, h.RemoveShortUrl)
+	router.GET("/:shortUrl", h.HandleShortUrlRedirect)
+	return router
+}
+
+func MockGetLink(t *testing.T, router *gin.Engine, shortUrl string) (int, handler.LinkDetails) {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/links/"+shortUrl, nil))
+
+	var details handler.LinkDetails
+	if w.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &details))
+	}
+	return w.Code, details
+}
+
+func TestGetLinkWithMetadata(t *testing.T) {
+	router := MockLinksRouter(t, store.NewMemoryStorageService(), UserId)
+
+	w := MockJSONPost(router, "/create-short-url", handler.UrlCreationRequest{
+		LongUrl:        "https://ultra.fandom.com/wiki/Ultraman_Tiga",
+		PredefinedName: "tiga",
+		Title:          "Ultraman Tiga",
+		Tags:           []string{" Tokusatsu ", "tsuburaya", "tokusatsu"},
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	code, details := MockGetLink(t, router, "tiga")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "tiga", details.ShortCode)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Tiga", details.LongUrl)
+	assert.Equal(t, UserId, details.Owner)
+	assert.Equal(t, "Ultraman Tiga", details.Title)
+	assert.Equal(t, []string{"tokusatsu", "tsuburaya"}, details.Tags)
+	assert.NotNil(t, details.CreatedAt)
+	assert.Equal(t, details.CreatedAt, details.UpdatedAt)
+	assert.Equal(t, http.StatusFound, details.RedirectType)
+	assert.Equal(t, store.QueryPrecedenceIncoming, details.QueryPrecedence)
+	assert.False(t, details.Expired)
+
+	title := "Ultraman Tiga (1996)"
+	w = MockJSONPost(router, "/update-url", handler.UrlUpdateRequest{
+		ShortUrl:   "tiga",
+		NewLongUrl: "https://ultra.fandom.com/wiki/Ultraman_Tiga_(series)",
+		Title:      &title,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	code, updated := MockGetLink(t, router, "tiga")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, title, updated.Title)
+	assert.Equal(t, []string{"tokusatsu", "tsuburaya"}, updated.Tags)
+	assert.Equal(t, details.CreatedAt, updated.CreatedAt)
+	assert.True(t, updated.UpdatedAt.After(*details.UpdatedAt) || updated.UpdatedAt.Equal(*details.UpdatedAt))
+}
+
+func TestCreateShortUrlWithInvalidTags(t *testing.T) {
+	router := MockLinksRouter(t, store.NewMemoryStorageService(), UserId)
+
+	w := MockJSONPost(router, "/create-short-url", handler.UrlCreationRequest{
+		LongUrl: "https://ultra.fandom.com/wiki/Ultraman_Tiga",
+		Tags:    []string{"tokusatsu", "  "},
+	})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Please input tags of 1 to 50 characters!")
+}
+
+func TestGetLinkNotOwner(t *testing.T) {
+	storageService := store.NewMemoryStorageService()
+	router := MockLinksRouter(t, storageService, UserId)
+	w := MockJSONPost(router, "/create-short-url", handler.UrlCreationRequest{
+		LongUrl:        "https://ultra.fandom.com/wiki/Ultraman_Tiga",
+		PredefinedName: "tiga",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	code, _ := MockGetLink(t, MockLinksRouter(t, storageService, "someone-else"), "tiga")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, _ = MockGetLink(t, router, "dyna")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestGetLinkStoredAsPlainString(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	assert.NoError(t, redisServer.Set("legacy", "https://youtu.be/8LhMu4bQTQU"))
+
+	router := MockLinksRouter(t, &store.StorageService{RedisClient: redisClient}, "")
+	code, details := MockGetLink(t, router, "legacy")
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "https://youtu.be/8LhMu4bQTQU", details.LongUrl)
+	assert.Empty(t, details.Owner)
+	assert.Nil(t, details.CreatedAt)
+	assert.Equal(t, []string{}, details.Tags)
+}
+
+func TestCreateShortUrlWithReservedPredefinedName(t *testing.T) {
+	router := MockLinksRouter(t, store.NewMemoryStorageService(), UserId)
+
+	for _, predefinedName := range []string{"deleted:links", "apikey:abc", "user:{bob}:links:created_at", "ratelimit:management:principal:x", "tiga/dyna", "{tiga}", "links", "admin", "create-short-url"} {
+		w := MockJSONPost(router, "/create-short-url", handler.UrlCreationRequest{
+			LongUrl:        "https://ultra.fandom.com/wiki/Ultraman_Tiga",
+			PredefinedName: predefinedName,
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code, predefinedName)
+	}
+
+	w := MockJSONPost(router, "/bulk-create-short-url", []handler.UrlCreationRequest{
+		{LongUrl: "https://ultra.fandom.com/wiki/Ultraman_Tiga", PredefinedName: "history:tiga"},
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"invalid"`)
+
+	w = MockJSONPost(router, "/create-short-url", handler.UrlCreationRequest{
+		LongUrl:        "https://ultra.fandom.com/wiki/Ultraman_Tiga",
+		PredefinedName: "stats",
+	})
+	assert.Contains(t, w.Body.String(), "it is a route of the service")
+}
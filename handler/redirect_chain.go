@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+const defaultMaxRedirectChain = 3
+
+var (
+	errRedirectLoop         = errors.New("Please input a url that doesn't redirect back to this short url!")
+	errRedirectChainTooLong = errors.New("Please input a url that goes through fewer short urls!")
+)
+
+// validateRedirectChain follows destination through the short urls served by
+// this service and rejects it when it leads back to shortUrl, loops, or goes
+// through more short urls than allowed. Chains are followed on the first path
+// segment only, like the redirect routes do.
+func (h *handler) validateRedirectChain(ctx context.Context, shortUrl string, destination string) error {
+	visited := map[string]bool{shortUrl: true}
+	maxChain := h.maxRedirectChain()
+
+	for hops := 0; ; hops++ {
+		code, ok := h.ownShortUrl(destination)
+		if !ok {
+			return nil
+		}
+		if visited[code] {
+			return errRedirectLoop
+		}
+		if hops >= maxChain {
+			return errRedirectChainTooLong
+		}
+		visited[code] = true
+
+		mapping, err := h.store.RetrieveUrlMapping(ctx, code)
+		if errors.Is(err, store.ErrUrlNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		destination = mapping.OriginalUrl
+	}
+}
+
+func (h *handler) maxRedirectChain() int {
+	if h.cfg.MaxRedirectChain > 0 {
+		return h.cfg.MaxRedirectChain
+	}
+	return defaultMaxRedirectChain
+}
+
+// ownShortUrl gives the short url destination points to when it is on one of
+// our own hosts. A host configured without a port matches any port.
+func (h *handler) ownShortUrl(destination string) (string, bool) {
+	u, err := url.Parse(destination)
+	if err != nil || !h.isOwnHost(u) {
+		return "", false
+	}
+
+	code := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)[0]
+	if code == "" {
+		return "", false
+	}
+	return code, true
+}
+
+func (h *handler) isOwnHost(u *url.URL) bool {
+	hosts := []string{h.cfg.ServerHost + ":" + h.cfg.ServerPort}
+	hosts = append(hosts, strings.Split(h.cfg.ShortLinkHosts, ",")...)
+
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host == "" {
+			continue
+		}
+		if host == hostWithPort(u) || (!strings.Contains(host, ":") && host == strings.ToLower(u.Hostname())) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostWithPort is the host of u with its port, the default port of its scheme
+// when it has none, as the normalizer strips default ports.
+func hostWithPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch strings.ToLower(u.Scheme) {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+	if port == "" {
+		return strings.ToLower(u.Host)
+	}
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+func isRedirectChainError(err error) bool {
+	return errors.Is(err, errRedirectLoop) || errors.Is(err, errRedirectChainTooLong)
+}
@@ -0,0 +1,171 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/config"
+	"source.golabs.io/daniel.santoso/url-blaster/handler"
+	"source.golabs.io/daniel.santoso/url-blaster/shortener"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func TestCreateShortUrlPointingToItself(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+
+	MockCreationJSONPost(c, handler.UrlCreationRequest{
+		LongUrl:        "http://LOCALHOST:9808/loop/extra",
+		UserId:         UserId,
+		PredefinedName: "loop",
+	})
+
+	h.CreateShortUrl(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.False(t, storageService.CheckIfShortUrlExists(context.TODO(), "loop"))
+}
+
+func TestUpdateLongUrlCreatingLoop(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	cfg.ShortLinkHosts = "sho.rt, links.example.com:8443"
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	redisClient.Set(ctx, "first", MockOwnedUrlMapping(t, "https://youtu.be/8LhMu4bQTQU"), CacheDuration)
+	redisClient.Set(ctx, "second", MockOwnedUrlMapping(t, "https://sho.rt/third"), CacheDuration)
+	redisClient.Set(ctx, "third", MockOwnedUrlMapping(t, "https://links.example.com:8443/first?utm_source=x"), CacheDuration)
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = &http.Request{
+		Header: make(http.Header),
+	}
+
+	MockUpdateJSONPost(c, handler.UrlUpdateRequest{
+		ShortUrl:   "first",
+		NewLongUrl: "http://localhost:9808/second",
+		UserId:     UserId,
+	})
+
+	h.UpdateLongUrl(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mapping, err := storageService.RetrieveUrlMapping(ctx, "first")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://youtu.be/8LhMu4bQTQU", mapping.OriginalUrl)
+}
+
+func TestCreateShortUrlThroughChainOfShortUrls(t *testing.T) {
+	shortener := shortener.NewShortener()
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+	cfg.MaxRedirectChain = 2
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	redisClient.Set(ctx, "one", MockOwnedUrlMapping(t, "http://localhost:9808/two"), CacheDuration)
+	redisClient.Set(ctx, "two", MockOwnedUrlMapping(t, "https://youtu.be/8LhMu4bQTQU"), CacheDuration)
+	redisClient.Set(ctx, "zero", MockOwnedUrlMapping(t, "http://localhost:9808/one"), CacheDuration)
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	h := handler.NewHandler(shortener, cfg, &storageService)
+
+	tests := map[string]int{
+		"http://localhost:9808/one":     http.StatusOK,
+		"http://localhost:9808/missing": http.StatusOK,
+		"http://localhost:9808/zero":    http.StatusBadRequest,
+	}
+	for longUrl, expectedCode := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		c.Request = &http.Request{
+			Header: make(http.Header),
+		}
+
+		MockCreationJSONPost(c, handler.UrlCreationRequest{
+			LongUrl: longUrl,
+			UserId:  UserId,
+		})
+
+		h.CreateShortUrl(c)
+
+		assert.Equal(t, expectedCode, w.Code, longUrl)
+	}
+}
+
+func TestCreateShortUrlPointingToItselfOnDefaultPort(t *testing.T) {
+	for _, testCase := range []struct {
+		port     string
+		longUrl  string
+		rejected bool
+	}{
+		{"443", "https://short.example.com/loop", true},
+		{"443", "https://short.example.com:443/loop", true},
+		{"443", "http://short.example.com/loop", false},
+		{"80", "http://short.example.com/loop", true},
+		{"80", "https://short.example.com/loop", false},
+	} {
+		cfg, err := config.NewConfig("../test.application.yml")
+		assert.NoError(t, err)
+		cfg.ServerHost = "short.example.com"
+		cfg.ServerPort = testCase.port
+		storageService := store.NewMemoryStorageService()
+		h := handler.NewHandler(shortener.NewShortener(), cfg, storageService)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		c.Request = &http.Request{
+			Header: make(http.Header),
+		}
+
+		MockCreationJSONPost(c, handler.UrlCreationRequest{
+			LongUrl:        testCase.longUrl,
+			UserId:         UserId,
+			PredefinedName: "loop",
+		})
+
+		h.CreateShortUrl(c)
+
+		assert.Equal(t, testCase.rejected, w.Code == http.StatusBadRequest, testCase.port+" "+testCase.longUrl)
+		assert.Equal(t, !testCase.rejected, storageService.CheckIfShortUrlExists(context.TODO(), "loop"), testCase.port+" "+testCase.longUrl)
+	}
+}
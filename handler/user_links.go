@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+const defaultLinksSort = "-" + store.SortByCreatedAt
+
+type UserLink struct {
+	ShortCode string     `json:"short_code"`
+	ShortUrl  string     `json:"short_url"`
+	LongUrl   string     `json:"long_url"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ListUserLinks pages through the short urls of a user. sort is a field,
+// created_at or updated_at, prefixed with "-" for descending order; q filters
+// on part of the long url. deleted=true lists the removed short urls that can
+// still be restored instead.
+func (h *handler) ListUserLinks(c *gin.Context) {
+	userId := c.Param("id")
+	if principal, ok := auth.Principal(c); ok && principal != userId {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only list your own short urls!"})
+		return
+	}
+
+	query, err := parseListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.store.ListUrlMappings(c, userId, query)
+	if errors.Is(err, store.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input a valid cursor!"})
+		return
+	}
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed listing url mappings | Error: %v - userId: %s", err, userId))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	links := make([]UserLink, len(page.Mappings))
+	for i, listed := range page.Mappings {
+		links[i] = UserLink{
+			ShortCode: listed.ShortUrl,
+			ShortUrl:  h.publicShortUrl(listed.ShortUrl),
+			LongUrl:   listed.OriginalUrl,
+			CreatedAt: listed.CreatedAt,
+			UpdatedAt: listed.UpdatedAt,
+			ExpiresAt: listed.ExpiresAt,
+			DeletedAt: listed.DeletedAt,
+		}
+	}
+
+	c.JSON(200, gin.H{
+		"links":       links,
+		"next_cursor": page.NextCursor,
+	})
+}
+
+func parseListQuery(c *gin.Context) (store.ListQuery, error) {
+	query := store.ListQuery{
+		Contains: c.Query("q"),
+		Cursor:   c.Query("cursor"),
+		Limit:    store.DefaultListLimit,
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > store.MaxListLimit {
+			return query, fmt.Errorf("Please input a limit between 1 and %d!", store.MaxListLimit)
+		}
+		query.Limit = limit
+	}
+
+	if value := c.Query("deleted"); value != "" {
+		deleted, err := strconv.ParseBool(value)
+		if err != nil {
+			return query, errors.New("Please input a deleted of true or false!")
+		}
+		query.Deleted = deleted
+	}
+
+	sort := c.DefaultQuery("sort", defaultLinksSort)
+	query.Ascending = !strings.HasPrefix(sort, "-")
+	query.SortBy = strings.TrimPrefix(sort, "-")
+	if query.SortBy != store.SortByCreatedAt && query.SortBy != store.SortByUpdatedAt {
+		return query, errors.New("Please input a sort of created_at or updated_at, optionally prefixed with -!")
+	}
+
+	return query, nil
+}
@@ -0,0 +1,127 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
+	"source.golabs.io/daniel.santoso/url-blaster/config"
+	"source.golabs.io/daniel.santoso/url-blaster/handler"
+	"source.golabs.io/daniel.santoso/url-blaster/shortener"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+type UserLinksResponse struct {
+	Links      []handler.UserLink `json:"links"`
+	NextCursor string             `json:"next_cursor"`
+}
+
+func MockUserLinksRouter(t *testing.T, principal string) (*gin.Engine, handler.HandlerI) {
+	cfg, err := config.NewConfig("../test.application.yml")
+	assert.NoError(t, err)
+
+	h := handler.NewHandler(shortener.NewShortener(), cfg, store.NewMemoryStorageService())
+	router := gin.New()
+	router.POST("/create-short-url", func(c *gin.Context) {
+		if principal != "" {
+			auth.SetPrincipal(c, principal)
+		}
+	}, h.CreateShortUrl)
+	router.GET("/users/:id/links", func(c *gin.Context) {
+		if principal != "" {
+			auth.SetPrincipal(c, principal)
+		}
+	}, h.ListUserLinks)
+	return router, h
+}
+
+func MockJSONPost(router *gin.Engine, path string, body interface{}) *httptest.ResponseRecorder {
+	jsonbytes, _ := json.Marshal(body)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(jsonbytes))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func MockListUserLinks(t *testing.T, router *gin.Engine, path string) (int, UserLinksResponse) {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var response UserLinksResponse
+	if w.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	}
+	return w.Code, response
+}
+
+func TestListUserLinks(t *testing.T) {
+	router, _ := MockUserLinksRouter(t, "")
+
+	for _, name := range []string{"cosmos", "dyna", "gaia"} {
+		w := MockJSONPost(router, "/create-short-url", handler.UrlCreationRequest{
+			LongUrl:        "https://ultra.fandom.com/wiki/Ultraman_" + name,
+			UserId:         UserId,
+			PredefinedName: name,
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	w := MockJSONPost(router, "/create-short-url", handler.UrlCreationRequest{
+		LongUrl: "https://youtu.be/8LhMu4bQTQU",
+		UserId:  "someone-else",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	code, response := MockListUserLinks(t, router, "/users/"+UserId+"/links?limit=2&sort=created_at")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, response.Links, 2) {
+		assert.Equal(t, "cosmos", response.Links[0].ShortCode)
+		assert.Equal(t, "http://localhost:9808/cosmos", response.Links[0].ShortUrl)
+		assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_cosmos", response.Links[0].LongUrl)
+		assert.NotNil(t, response.Links[0].CreatedAt)
+		assert.NotNil(t, response.Links[0].UpdatedAt)
+	}
+	assert.NotEmpty(t, response.NextCursor)
+
+	code, response = MockListUserLinks(t, router, "/users/"+UserId+"/links?limit=2&sort=created_at&cursor="+response.NextCursor)
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, response.Links, 1) {
+		assert.Equal(t, "gaia", response.Links[0].ShortCode)
+	}
+	assert.Empty(t, response.NextCursor)
+
+	code, response = MockListUserLinks(t, router, "/users/"+UserId+"/links?q=DYNA")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, response.Links, 1) {
+		assert.Equal(t, "dyna", response.Links[0].ShortCode)
+	}
+
+	code, response = MockListUserLinks(t, router, "/users/nobody/links")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Links)
+}
+
+func TestListUserLinksInvalidQuery(t *testing.T) {
+	router, _ := MockUserLinksRouter(t, "")
+
+	for _, query := range []string{"limit=0", "limit=101", "limit=ten", "sort=owner", "cursor=hahaha"} {
+		code, _ := MockListUserLinks(t, router, "/users/"+UserId+"/links?"+query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+func TestListUserLinksOfAnotherPrincipal(t *testing.T) {
+	router, _ := MockUserLinksRouter(t, UserId)
+
+	code, _ := MockListUserLinks(t, router, "/users/someone-else/links")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, _ = MockListUserLinks(t, router, "/users/"+UserId+"/links")
+	assert.Equal(t, http.StatusOK, code)
+}
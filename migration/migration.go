@@ -0,0 +1,58 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrSkip is returned by Step.Rewrite or Step.Rename to leave a key as it is.
+var ErrSkip = errors.New("key skipped")
+
+// Step is one versioned change to the keyspace. Rewrite gets the value of
+// every key the scan finds and returns its new value. An interrupted step is
+// resumed from its checkpoint and can see keys it already rewrote, so Rewrite
+// has to skip keys that are already migrated.
+//
+// Keys are given without the key prefix of the service.
+type Step struct {
+	Version int
+	Name    string
+
+	// Match is the SCAN pattern of the keys to rewrite, empty for all keys.
+	Match string
+	// Type is the Redis type of the keys to rewrite, empty for all types.
+	Type string
+	// Unprefixed steps scan the keys outside of the key prefix, written
+	// before keys were prefixed, instead of the keys under it.
+	Unprefixed bool
+
+	Rewrite func(key, value string) (string, error)
+	// Rename, when set instead of Rewrite, moves keys of any type to the name
+	// it returns under the key prefix. keyType is the Redis type of the key,
+	// value is empty unless it is a string.
+	Rename func(key, keyType, value string, opts Options) (string, error)
+}
+
+var registry = map[int]Step{}
+
+// Register adds a step to the migrations run by the migrate command. Versions
+// are unique and steps run in version order.
+func Register(step Step) {
+	if _, ok := registry[step.Version]; ok {
+		panic(fmt.Sprintf("migration %d is registered twice", step.Version))
+	}
+	registry[step.Version] = step
+}
+
+// Steps returns the registered steps ordered by version.
+func Steps() []Step {
+	steps := make([]Step, 0, len(registry))
+	for _, step := range registry {
+		steps = append(steps, step)
+	}
+	sort.Slice(steps, func(i, j int) bool {
+		return steps[i].Version < steps[j].Version
+	})
+	return steps
+}
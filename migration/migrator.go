@@ -0,0 +1,411 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultBatchSize = 500
+
+	versionKey         = "migration:version"
+	maxRewriteAttempts = 3
+	scanDone           = "done"
+)
+
+var errTargetExists = errors.New("key already exists under the key prefix")
+
+type Options struct {
+	// BatchSize is the SCAN count, and how many keys are rewritten between
+	// two checkpoints.
+	BatchSize int64
+	// DryRun counts what would be migrated without writing anything, not even
+	// the checkpoint.
+	DryRun bool
+	// TargetVersion stops after this version, 0 runs every pending step.
+	TargetVersion int
+	// KeyPrefix is the namespace of the service in Redis, see
+	// store.RedisKeyPrefix. The migration state is kept under it too.
+	KeyPrefix string
+	// IncludeLegacyStrings lets the key-prefix migration also move strings
+	// holding a bare url, which can't be told apart from other applications'
+	// keys.
+	IncludeLegacyStrings bool
+}
+
+type Report struct {
+	Version  int
+	Name     string
+	Migrated int64
+	Skipped  int64
+	Failed   int64
+}
+
+type MigratorI interface {
+	CurrentVersion(ctx context.Context) (int, error)
+	Run(ctx context.Context) ([]Report, error)
+}
+
+type Migrator struct {
+	redisClient redis.UniversalClient
+	steps       []Step
+	opts        Options
+}
+
+type scanNode struct {
+	name   string
+	client redis.Cmdable
+}
+
+func NewMigrator(redisClient redis.UniversalClient, steps []Step, opts Options) *Migrator {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	return &Migrator{
+		redisClient: redisClient,
+		steps:       steps,
+		opts:        opts,
+	}
+}
+
+// checkpointKey is a hash with the counts of a step so far and, per scanned
+// node, the SCAN cursor to continue from.
+func (m *Migrator) checkpointKey(version int) string {
+	return fmt.Sprintf("%smigration:{%d}:checkpoint", m.opts.KeyPrefix, version)
+}
+
+func cursorField(node string) string {
+	return "cursor:" + node
+}
+
+// CurrentVersion falls back to the version recorded before keys were
+// prefixed, until the migration moving the keys under the prefix has run.
+func (m *Migrator) CurrentVersion(ctx context.Context) (int, error) {
+	value, err := m.redisClient.Get(ctx, m.opts.KeyPrefix+versionKey).Result()
+	if err == redis.Nil && m.opts.KeyPrefix != "" {
+		value, err = m.redisClient.Get(ctx, versionKey).Result()
+	}
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+// Run applies the pending steps in order. A step that failed for some keys
+// stops the run and is not recorded as applied, running it again rescans the
+// whole keyspace.
+func (m *Migrator) Run(ctx context.Context) ([]Report, error) {
+	current, err := m.CurrentVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []Report
+	for _, step := range m.steps {
+		if step.Version <= current {
+			continue
+		}
+		if m.opts.TargetVersion > 0 && step.Version > m.opts.TargetVersion {
+			break
+		}
+
+		report, err := m.runStep(ctx, step)
+		reports = append(reports, report)
+		if err != nil {
+			return reports, err
+		}
+		if m.opts.DryRun {
+			continue
+		}
+
+		_, err = m.redisClient.Del(ctx, m.checkpointKey(step.Version)).Result()
+		if err != nil {
+			return reports, err
+		}
+		if report.Failed > 0 {
+			return reports, fmt.Errorf("migration %d %s failed for %d keys", step.Version, step.Name, report.Failed)
+		}
+		err = m.redisClient.Set(ctx, m.opts.KeyPrefix+versionKey, step.Version, 0).Err()
+		if err != nil {
+			return reports, err
+		}
+	}
+
+	return reports, nil
+}
+
+func (m *Migrator) runStep(ctx context.Context, step Step) (Report, error) {
+	report := Report{Version: step.Version, Name: step.Name}
+
+	checkpoint := map[string]string{}
+	if !m.opts.DryRun {
+		var err error
+		checkpoint, err = m.redisClient.HGetAll(ctx, m.checkpointKey(step.Version)).Result()
+		if err != nil {
+			return report, err
+		}
+		report.Migrated, _ = strconv.ParseInt(checkpoint["migrated"], 10, 64)
+		report.Skipped, _ = strconv.ParseInt(checkpoint["skipped"], 10, 64)
+		report.Failed, _ = strconv.ParseInt(checkpoint["failed"], 10, 64)
+	}
+
+	if step.Unprefixed && m.opts.KeyPrefix == "" {
+		return report, nil
+	}
+
+	nodes, err := m.scanNodes(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	match := step.Match
+	if match == "" {
+		match = "*"
+	}
+	if !step.Unprefixed {
+		match = escapePattern(m.opts.KeyPrefix) + match
+	}
+
+	for _, node := range nodes {
+		if checkpoint[cursorField(node.name)] == scanDone {
+			continue
+		}
+		cursor, _ := strconv.ParseUint(checkpoint[cursorField(node.name)], 10, 64)
+
+		for {
+			var keys []string
+			if step.Type != "" {
+				keys, cursor, err = node.client.ScanType(ctx, cursor, match, m.opts.BatchSize, step.Type).Result()
+			} else {
+				keys, cursor, err = node.client.Scan(ctx, cursor, match, m.opts.BatchSize).Result()
+			}
+			if err != nil {
+				return report, err
+			}
+
+			var batch Report
+			for _, key := range keys {
+				err := m.migrateKey(ctx, step, key)
+				switch {
+				case errors.Is(err, ErrSkip):
+					batch.Skipped++
+				case err != nil:
+					log.Err(err).Msg(fmt.Sprintf("Failed migrating key | Error: %v - migration: %d - key: %s", err, step.Version, key))
+					batch.Failed++
+				default:
+					batch.Migrated++
+				}
+			}
+			report.Migrated += batch.Migrated
+			report.Skipped += batch.Skipped
+			report.Failed += batch.Failed
+
+			next := strconv.FormatUint(cursor, 10)
+			if cursor == 0 {
+				next = scanDone
+			}
+			if !m.opts.DryRun {
+				err = m.saveCheckpoint(ctx, step.Version, node.name, next, batch)
+				if err != nil {
+					return report, err
+				}
+			}
+
+			log.Info().Msg(fmt.Sprintf("Migration %d %s | node: %s - migrated: %d - skipped: %d - failed: %d", step.Version, step.Name, node.name, report.Migrated, report.Skipped, report.Failed))
+			if cursor == 0 {
+				break
+			}
+		}
+	}
+
+	return report, nil
+}
+
+func (m *Migrator) saveCheckpoint(ctx context.Context, version int, node, cursor string, batch Report) error {
+	key := m.checkpointKey(version)
+	_, err := m.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, cursorField(node), cursor)
+		pipe.HIncrBy(ctx, key, "migrated", batch.Migrated)
+		pipe.HIncrBy(ctx, key, "skipped", batch.Skipped)
+		pipe.HIncrBy(ctx, key, "failed", batch.Failed)
+		return nil
+	})
+	return err
+}
+
+func (m *Migrator) migrateKey(ctx context.Context, step Step, key string) error {
+	name := strings.TrimPrefix(key, m.opts.KeyPrefix)
+	if step.Unprefixed {
+		if strings.HasPrefix(key, m.opts.KeyPrefix) {
+			return ErrSkip
+		}
+		name = key
+	}
+
+	if step.Rename != nil {
+		return m.renameKey(ctx, step, key, name)
+	}
+	return m.rewriteKey(ctx, step, key, name)
+}
+
+// rewriteKey replaces the value of key in a transaction, keeping its TTL, so
+// a write by the service in between is never overwritten with a stale value.
+func (m *Migrator) rewriteKey(ctx context.Context, step Step, key, name string) error {
+	rewrite := func(tx *redis.Tx) error {
+		value, err := tx.Get(ctx, key).Result()
+		if err == redis.Nil {
+			return ErrSkip
+		}
+		if err != nil {
+			return err
+		}
+
+		rewritten, err := step.Rewrite(name, value)
+		if err != nil {
+			return err
+		}
+		if m.opts.DryRun {
+			return nil
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, rewritten, redis.KeepTTL)
+			return nil
+		})
+		return err
+	}
+
+	var err error
+	for attempt := 0; attempt < maxRewriteAttempts; attempt++ {
+		err = m.redisClient.Watch(ctx, rewrite, key)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return err
+}
+
+// renameKey never overwrites a key that already exists under the new name.
+func (m *Migrator) renameKey(ctx context.Context, step Step, key, name string) error {
+	keyType, err := m.redisClient.Type(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if keyType == "none" {
+		return ErrSkip
+	}
+
+	var value string
+	if keyType == "string" {
+		value, err = m.redisClient.Get(ctx, key).Result()
+		if err == redis.Nil {
+			return ErrSkip
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	renamed, err := step.Rename(name, keyType, value, m.opts)
+	if err != nil {
+		return err
+	}
+	target := m.opts.KeyPrefix + renamed
+	if target == key {
+		return ErrSkip
+	}
+	if m.opts.DryRun {
+		return nil
+	}
+
+	if _, ok := m.redisClient.(*redis.ClusterClient); ok {
+		return m.moveClusterKey(ctx, key, target)
+	}
+
+	moved, err := m.redisClient.RenameNX(ctx, key, target).Result()
+	if err != nil {
+		return err
+	}
+	if !moved {
+		return errTargetExists
+	}
+	return nil
+}
+
+// moveClusterKey copies the key and deletes the original, as the new name
+// usually hashes to another slot, which RENAME can't move a key to.
+func (m *Migrator) moveClusterKey(ctx context.Context, key, target string) error {
+	dump, err := m.redisClient.Dump(ctx, key).Result()
+	if err == redis.Nil {
+		return ErrSkip
+	}
+	if err != nil {
+		return err
+	}
+
+	ttl, err := m.redisClient.PTTL(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	err = m.redisClient.Restore(ctx, target, ttl, dump).Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYKEY") {
+		return errTargetExists
+	}
+	if err != nil {
+		return err
+	}
+	return m.redisClient.Del(ctx, key).Err()
+}
+
+// escapePattern makes the key prefix match itself in a SCAN pattern.
+func escapePattern(prefix string) string {
+	var escaped strings.Builder
+	for _, r := range prefix {
+		if strings.ContainsRune(`*?[]\`, r) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
+
+// scanNodes lists what has to be scanned: every master of a cluster, since a
+// SCAN only covers the node it runs on, or the single Redis otherwise.
+func (m *Migrator) scanNodes(ctx context.Context) ([]scanNode, error) {
+	clusterClient, ok := m.redisClient.(*redis.ClusterClient)
+	if !ok {
+		return []scanNode{{name: "default", client: m.redisClient}}, nil
+	}
+
+	var mu sync.Mutex
+	var nodes []scanNode
+	err := clusterClient.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		mu.Lock()
+		defer mu.Unlock()
+		nodes = append(nodes, scanNode{name: client.Options().Addr, client: client})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].name < nodes[j].name
+	})
+	return nodes, nil
+}
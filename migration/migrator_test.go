@@ -0,0 +1,212 @@
+package migration_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/migration"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func MockRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	return redisServer, redisClient
+}
+
+func TestJsonRecordsMigration(t *testing.T) {
+	redisServer, redisClient := MockRedis(t)
+	storageService := store.StorageService{RedisClient: redisClient}
+
+	assert.NoError(t, redisServer.Set("cosmos", "https://ultra.fandom.com/wiki/Ultraman_Cosmos"))
+	redisServer.SetTTL("cosmos", time.Hour)
+	assert.NoError(t, storageService.SaveUrlMapping(context.TODO(), "dyna", store.UrlMapping{
+		OriginalUrl: "https://ultra.fandom.com/wiki/Ultraman_Dyna",
+		Owner:       "e0dba740",
+	}))
+	assert.NoError(t, redisServer.Set("stats:{cosmos}:clicks", "7"))
+	assert.NoError(t, redisServer.Set("ratelimit:redirect:ip:127.0.0.1", "1668000000000"))
+	redisServer.HSet("stats:{cosmos}:daily", "2022-11-09", "7")
+
+	migrator := migration.NewMigrator(redisClient, migration.Steps(), migration.Options{})
+	reports, err := migrator.Run(context.TODO())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []migration.Report{
+		{Version: 1, Name: "json-records", Migrated: 1, Skipped: 3},
+		{Version: 2, Name: "key-prefix"},
+	}, reports)
+
+	value, err := redisServer.Get("cosmos")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"original_url":"https://ultra.fandom.com/wiki/Ultraman_Cosmos"}`, value)
+	assert.Equal(t, time.Hour, redisServer.TTL("cosmos"))
+
+	clicks, err := redisServer.Get("stats:{cosmos}:clicks")
+	assert.NoError(t, err)
+	assert.Equal(t, "7", clicks)
+
+	mapping, err := storageService.RetrieveUrlMapping(context.TODO(), "dyna")
+	assert.NoError(t, err)
+	assert.Equal(t, "e0dba740", mapping.Owner)
+
+	version, err := migrator.CurrentVersion(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+	assert.False(t, redisServer.Exists("migration:{1}:checkpoint"))
+
+	reports, err = migrator.Run(context.TODO())
+	assert.NoError(t, err)
+	assert.Empty(t, reports)
+}
+
+func TestMigrationDryRun(t *testing.T) {
+	redisServer, redisClient := MockRedis(t)
+	assert.NoError(t, redisServer.Set("cosmos", "https://ultra.fandom.com/wiki/Ultraman_Cosmos"))
+
+	migrator := migration.NewMigrator(redisClient, migration.Steps(), migration.Options{DryRun: true})
+	reports, err := migrator.Run(context.TODO())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), reports[0].Migrated)
+
+	value, err := redisServer.Get("cosmos")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Cosmos", value)
+	assert.False(t, redisServer.Exists("migration:version"))
+	assert.False(t, redisServer.Exists("migration:{1}:checkpoint"))
+}
+
+func TestMigrationResumesFromCheckpoint(t *testing.T) {
+	redisServer, redisClient := MockRedis(t)
+	assert.NoError(t, redisServer.Set("cosmos", "https://ultra.fandom.com/wiki/Ultraman_Cosmos"))
+	redisServer.HSet("migration:{1}:checkpoint", "cursor:default", "done", "migrated", "41", "skipped", "2")
+
+	migrator := migration.NewMigrator(redisClient, migration.Steps(), migration.Options{})
+	reports, err := migrator.Run(context.TODO())
+
+	assert.NoError(t, err)
+	assert.Equal(t, migration.Report{Version: 1, Name: "json-records", Migrated: 41, Skipped: 2}, reports[0])
+
+	value, err := redisServer.Get("cosmos")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Cosmos", value)
+}
+
+func TestMigrationWithFailedKeys(t *testing.T) {
+	redisServer, redisClient := MockRedis(t)
+	assert.NoError(t, redisServer.Set("cosmos", "https://ultra.fandom.com/wiki/Ultraman_Cosmos"))
+	assert.NoError(t, redisServer.Set("dyna", "https://ultra.fandom.com/wiki/Ultraman_Dyna"))
+
+	steps := []migration.Step{{
+		Version: 1,
+		Name:    "fails-on-dyna",
+		Rewrite: func(key, value string) (string, error) {
+			if key == "dyna" {
+				return "", errors.New("unexpected value")
+			}
+			return value + "#migrated", nil
+		},
+	}, {
+		Version: 2,
+		Name:    "never-runs",
+		Rewrite: func(key, value string) (string, error) {
+			return "", migration.ErrSkip
+		},
+	}}
+
+	migrator := migration.NewMigrator(redisClient, steps, migration.Options{BatchSize: 1})
+	reports, err := migrator.Run(context.TODO())
+
+	assert.EqualError(t, err, "migration 1 fails-on-dyna failed for 1 keys")
+	assert.Equal(t, []migration.Report{{Version: 1, Name: "fails-on-dyna", Migrated: 1, Failed: 1}}, reports)
+
+	version, err := migrator.CurrentVersion(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+	assert.False(t, redisServer.Exists("migration:{1}:checkpoint"))
+}
+
+func TestKeyPrefixMigration(t *testing.T) {
+	redisServer, redisClient := MockRedis(t)
+	assert.NoError(t, redisServer.Set("migration:version", "1"))
+	assert.NoError(t, redisServer.Set("cosmos", `{"original_url":"https://ultra.fandom.com/wiki/Ultraman_Cosmos","owner":"e0dba740"}`))
+	redisServer.SetTTL("cosmos", time.Hour)
+	assert.NoError(t, redisServer.Set("dyna", "https://ultra.fandom.com/wiki/Ultraman_Dyna"))
+	_, err := redisServer.ZAdd("user:{e0dba740}:links:created_at", 1, "cosmos")
+	assert.NoError(t, err)
+	redisServer.HSet("stats:{cosmos}:daily", "2022-11-09", "7")
+	assert.NoError(t, redisServer.Set("session:42", "someone else's"))
+	assert.NoError(t, redisServer.Set("history:42", "someone else's"))
+	assert.NoError(t, redisServer.Set("gaia", "https://ultra.fandom.com/wiki/Ultraman_Gaia"))
+	assert.NoError(t, redisServer.Set("urlblaster:gaia", `{"original_url":"https://ultra.fandom.com/wiki/Ultraman_Gaia_(series)"}`))
+
+	migrator := migration.NewMigrator(redisClient, migration.Steps(), migration.Options{KeyPrefix: "urlblaster:", IncludeLegacyStrings: true})
+	reports, err := migrator.Run(context.TODO())
+
+	assert.EqualError(t, err, "migration 2 key-prefix failed for 1 keys")
+	assert.Equal(t, []migration.Report{{Version: 2, Name: "key-prefix", Migrated: 5, Skipped: 3, Failed: 1}}, reports)
+
+	storageService := store.StorageService{RedisClient: redisClient, KeyPrefix: "urlblaster:"}
+	mapping, err := storageService.RetrieveUrlMapping(context.TODO(), "cosmos")
+	assert.NoError(t, err)
+	assert.Equal(t, "e0dba740", mapping.Owner)
+	assert.Equal(t, time.Hour, redisServer.TTL("urlblaster:cosmos"))
+	initialUrl, err := storageService.RetrieveInitialUrl(context.TODO(), "dyna")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Dyna", initialUrl)
+	initialUrl, err = storageService.RetrieveInitialUrl(context.TODO(), "gaia")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ultra.fandom.com/wiki/Ultraman_Gaia_(series)", initialUrl)
+
+	assert.True(t, redisServer.Exists("urlblaster:user:{e0dba740}:links:created_at"))
+	assert.True(t, redisServer.Exists("urlblaster:stats:{cosmos}:daily"))
+	assert.True(t, redisServer.Exists("session:42"))
+	assert.True(t, redisServer.Exists("history:42"))
+	assert.True(t, redisServer.Exists("gaia"))
+	assert.False(t, redisServer.Exists("cosmos"))
+
+	version, err := migrator.CurrentVersion(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	redisServer.Del("gaia")
+	reports, err = migrator.Run(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), reports[0].Migrated)
+	assert.Equal(t, int64(0), reports[0].Failed)
+
+	version, err = migrator.CurrentVersion(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+}
+
+func TestKeyPrefixMigrationLeavesOtherKeys(t *testing.T) {
+	redisServer, redisClient := MockRedis(t)
+	assert.NoError(t, redisServer.Set("migration:version", "1"))
+	assert.NoError(t, redisServer.Set("cosmos", `{"original_url":"https://ultra.fandom.com/wiki/Ultraman_Cosmos"}`))
+	_, err := redisServer.Lpush("history:cosmos", `{"version":1,"new_url":"https://ultra.fandom.com/wiki/Ultraman_Cosmos"}`)
+	assert.NoError(t, err)
+	assert.NoError(t, redisServer.Set("dyna", "https://ultra.fandom.com/wiki/Ultraman_Dyna"))
+	assert.NoError(t, redisServer.Set("bookmark:7", `{"original_url":"https://ultra.fandom.com","folder":"tokusatsu"}`))
+	redisServer.HSet("user:{e0dba740}:links:created_at", "name", "someone else's")
+	redisServer.HSet("stats:{cosmos}:pageviews", "2022-11-09", "7")
+
+	migrator := migration.NewMigrator(redisClient, migration.Steps(), migration.Options{KeyPrefix: "urlblaster:"})
+	reports, err := migrator.Run(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, []migration.Report{{Version: 2, Name: "key-prefix", Migrated: 3, Skipped: 4}}, reports)
+
+	assert.True(t, redisServer.Exists("urlblaster:cosmos"))
+	assert.True(t, redisServer.Exists("urlblaster:history:cosmos"))
+	for _, key := range []string{"dyna", "bookmark:7", "user:{e0dba740}:links:created_at", "stats:{cosmos}:pageviews"} {
+		assert.True(t, redisServer.Exists(key), key)
+	}
+}
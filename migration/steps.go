@@ -0,0 +1,98 @@
+package migration
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func init() {
+	Register(Step{
+		Version: 1,
+		Name:    "json-records",
+		Type:    "string",
+		Rewrite: rewriteJsonRecord,
+	})
+	Register(Step{
+		Version:    2,
+		Name:       "key-prefix",
+		Unprefixed: true,
+		Rename:     renameUnderKeyPrefix,
+	})
+}
+
+// rewriteJsonRecord turns the plain original url values written before
+// mappings were stored as JSON records into records. The store still reads
+// plain values, this only saves decoding them differently forever.
+func rewriteJsonRecord(key, value string) (string, error) {
+	if !store.IsUrlMappingKey(key) || strings.HasPrefix(value, "{") {
+		return "", ErrSkip
+	}
+
+	record, err := json.Marshal(store.UrlMapping{OriginalUrl: value})
+	if err != nil {
+		return "", err
+	}
+	return string(record), nil
+}
+
+// ownKeyLayouts are the keys the service writes besides the url mappings,
+// with their Redis type.
+var ownKeyLayouts = []struct {
+	pattern *regexp.Regexp
+	keyType string
+}{
+	{regexp.MustCompile(`^apikey:[^:]+$`), "string"},
+	{regexp.MustCompile(`^user:\{[^}]*\}:links:(created_at|updated_at)$`), "zset"},
+	{regexp.MustCompile(`^history:.+$`), "list"},
+	{regexp.MustCompile(`^deleted:links$`), "zset"},
+	{regexp.MustCompile(`^stats:\{[^}]*\}:(clicks|visitors)$`), "string"},
+	{regexp.MustCompile(`^stats:\{[^}]*\}:(daily|daily:\d{4}-\d{2}|referrers|user_agents|hourly:\d{4}-\d{2}-\d{2})$`), "hash"},
+	{regexp.MustCompile(`^ratelimit:.+$`), "string"},
+	{regexp.MustCompile(`^migration:version$`), "string"},
+	{regexp.MustCompile(`^migration:\{\d+\}:checkpoint$`), "hash"},
+	{regexp.MustCompile(`^audit:log$`), "stream"},
+}
+
+// renameUnderKeyPrefix moves the keys written before keys were prefixed under
+// the key prefix. Other applications may share the Redis, so only keys laid
+// out exactly as ours are moved: strings holding a JSON url mapping record,
+// and the keys of ownKeyLayouts with the right type. Strings holding a bare
+// url, as written before the json-records migration, look like anyone's, so
+// they are only moved with Options.IncludeLegacyStrings.
+func renameUnderKeyPrefix(key, keyType, value string, opts Options) (string, error) {
+	if !store.IsUrlMappingKey(key) {
+		for _, layout := range ownKeyLayouts {
+			if layout.pattern.MatchString(key) && layout.keyType == keyType {
+				return key, nil
+			}
+		}
+		return "", ErrSkip
+	}
+
+	if keyType != "string" {
+		return "", ErrSkip
+	}
+	if isUrlMappingRecord(value) || opts.IncludeLegacyStrings && isUrl(value) {
+		return key, nil
+	}
+	return "", ErrSkip
+}
+
+// isUrlMappingRecord refuses fields a url mapping doesn't have, so JSON of
+// other applications that happens to hold an original_url isn't taken.
+func isUrlMappingRecord(value string) bool {
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+
+	var mapping store.UrlMapping
+	return decoder.Decode(&mapping) == nil && mapping.OriginalUrl != ""
+}
+
+func isUrl(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && parsed.Scheme != "" && parsed.Host != ""
+}
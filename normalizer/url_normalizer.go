@@ -0,0 +1,151 @@
+package normalizer
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+	"source.golabs.io/daniel.santoso/url-blaster/config"
+)
+
+const (
+	DefaultAllowedSchemes = "http,https"
+	DefaultMaxUrlLength   = 2048
+)
+
+var (
+	ErrInvalidUrl       = errors.New("Please input a valid url!")
+	ErrUrlTooLong       = errors.New("Please input a shorter url!")
+	ErrSchemeNotAllowed = errors.New("Please input a url with an allowed scheme!")
+	ErrHostRequired     = errors.New("Please input a url with a host!")
+	ErrInvalidHost      = errors.New("Please input a url with a valid host!")
+	ErrCredentialsInUrl = errors.New("Please input a url without credentials!")
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+type NormalizerI interface {
+	Normalize(rawUrl string) (string, error)
+}
+
+type normalizer struct {
+	allowedSchemes map[string]bool
+	maxLength      int
+	stripFragment  bool
+}
+
+func NewNormalizer(cfg *config.Config) NormalizerI {
+	schemes := cfg.UrlAllowedSchemes
+	if schemes == "" {
+		schemes = DefaultAllowedSchemes
+	}
+
+	allowedSchemes := make(map[string]bool)
+	for _, scheme := range strings.Split(schemes, ",") {
+		if scheme = strings.ToLower(strings.TrimSpace(scheme)); scheme != "" {
+			allowedSchemes[scheme] = true
+		}
+	}
+
+	maxLength := cfg.UrlMaxLength
+	if maxLength <= 0 {
+		maxLength = DefaultMaxUrlLength
+	}
+
+	return &normalizer{
+		allowedSchemes: allowedSchemes,
+		maxLength:      maxLength,
+		stripFragment:  cfg.UrlStripFragment,
+	}
+}
+
+// Normalize validates rawUrl and returns its canonical form, so that
+// equivalent urls always hash to the same short url.
+func (n *normalizer) Normalize(rawUrl string) (string, error) {
+	rawUrl = strings.TrimSpace(rawUrl)
+	if rawUrl == "" {
+		return "", ErrInvalidUrl
+	}
+	if len(rawUrl) > n.maxLength {
+		return "", ErrUrlTooLong
+	}
+	if strings.IndexFunc(rawUrl, isForbiddenRune) >= 0 {
+		return "", ErrInvalidUrl
+	}
+
+	u, err := url.Parse(rawUrl)
+	if err != nil || u.Opaque != "" {
+		return "", ErrInvalidUrl
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme == "" {
+		return "", ErrInvalidUrl
+	}
+	if !n.allowedSchemes[u.Scheme] {
+		return "", ErrSchemeNotAllowed
+	}
+
+	if u.User != nil {
+		return "", ErrCredentialsInUrl
+	}
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+
+	port := u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host = host + ":" + port
+	}
+	u.Host = host
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	if n.stripFragment {
+		u.Fragment = ""
+		u.RawFragment = ""
+	}
+
+	normalized := u.String()
+	if len(normalized) > n.maxLength {
+		return "", ErrUrlTooLong
+	}
+	return normalized, nil
+}
+
+// normalizeHost lowercases the host and converts internationalized domain
+// names to punycode. IP literals are returned in their canonical form.
+func normalizeHost(host string) (string, error) {
+	if host == "" {
+		return "", ErrHostRequired
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(strings.TrimSuffix(host, "."))
+	if err != nil || ascii == "" {
+		return "", ErrInvalidHost
+	}
+	return strings.ToLower(ascii), nil
+}
+
+func isForbiddenRune(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsControl(r)
+}
@@ -0,0 +1,209 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/idna"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultReloadInterval = 10 * time.Second
+
+	wildcardPrefix = "*."
+	regexPrefix    = "re:"
+)
+
+var ErrDomainBlocked = errors.New("Please input a url with an allowed domain!")
+
+type DomainPolicyI interface {
+	Check(rawUrl string) error
+}
+
+// domainPolicyFile is the on-disk format. Entries are either an exact domain
+// ("example.com"), a wildcard matching every subdomain ("*.example.com") or a
+// regular expression matched against the whole host ("re:^login-.*\.com$").
+type domainPolicyFile struct {
+	Blocklist []string `yaml:"blocklist"`
+	Allowlist []string `yaml:"allowlist"`
+}
+
+type domainRule struct {
+	exact   string
+	suffix  string
+	pattern *regexp.Regexp
+}
+
+type domainRules struct {
+	blocklist []domainRule
+	allowlist []domainRule
+}
+
+// DomainPolicy blocks destinations matching the blocklist and, when the
+// allowlist is not empty, every destination not matching the allowlist.
+type DomainPolicy struct {
+	path  string
+	rules atomic.Value
+
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+func NewDomainPolicy(path string) (*DomainPolicy, error) {
+	p := &DomainPolicy{path: path}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload reads the policy file again. On error the previous rules are kept.
+func (p *DomainPolicy) Reload() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return err
+	}
+
+	content, err := os.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+
+	var file domainPolicyFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return fmt.Errorf("parse domain policy %s: %w", p.path, err)
+	}
+
+	rules := &domainRules{}
+	if rules.blocklist, err = parseDomainRules(file.Blocklist); err != nil {
+		return err
+	}
+	if rules.allowlist, err = parseDomainRules(file.Allowlist); err != nil {
+		return err
+	}
+
+	p.rules.Store(rules)
+	p.modTime = info.ModTime()
+	return nil
+}
+
+// Watch reloads the policy file whenever its modification time changes,
+// until ctx is done.
+func (p *DomainPolicy) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !p.changed() {
+				continue
+			}
+			if err := p.Reload(); err != nil {
+				log.Err(err).Msg(fmt.Sprintf("Failed reloading domain policy, keeping the previous rules | path: %s", p.path))
+				continue
+			}
+			log.Info().Msg(fmt.Sprintf("Reloaded domain policy | path: %s", p.path))
+		}
+	}
+}
+
+func (p *DomainPolicy) changed() bool {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed checking domain policy | path: %s", p.path))
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !info.ModTime().Equal(p.modTime)
+}
+
+func (p *DomainPolicy) Check(rawUrl string) error {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return ErrDomainBlocked
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	rules := p.rules.Load().(*domainRules)
+	if matchesAny(rules.blocklist, host) {
+		return ErrDomainBlocked
+	}
+	if len(rules.allowlist) > 0 && !matchesAny(rules.allowlist, host) {
+		return ErrDomainBlocked
+	}
+	return nil
+}
+
+func parseDomainRules(entries []string) ([]domainRule, error) {
+	rules := make([]domainRule, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if strings.HasPrefix(entry, regexPrefix) {
+			pattern, err := regexp.Compile(strings.TrimPrefix(entry, regexPrefix))
+			if err != nil {
+				return nil, fmt.Errorf("invalid domain pattern %q: %w", entry, err)
+			}
+			rules = append(rules, domainRule{pattern: pattern})
+			continue
+		}
+
+		wildcard := strings.HasPrefix(entry, wildcardPrefix)
+		domain, err := idna.Lookup.ToASCII(strings.TrimSuffix(strings.TrimPrefix(entry, wildcardPrefix), "."))
+		if err != nil || domain == "" {
+			return nil, fmt.Errorf("invalid domain %q", entry)
+		}
+		domain = strings.ToLower(domain)
+
+		if wildcard {
+			rules = append(rules, domainRule{suffix: "." + domain})
+		} else {
+			rules = append(rules, domainRule{exact: domain})
+		}
+	}
+	return rules, nil
+}
+
+func matchesAny(rules []domainRule, host string) bool {
+	for _, rule := range rules {
+		if rule.matches(host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r domainRule) matches(host string) bool {
+	switch {
+	case r.pattern != nil:
+		return r.pattern.MatchString(host)
+	case r.suffix != "":
+		return strings.HasSuffix(host, r.suffix)
+	default:
+		return host == r.exact
+	}
+}
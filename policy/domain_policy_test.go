@@ -0,0 +1,111 @@
+package policy_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/policy"
+)
+
+func writePolicyFile(t *testing.T, path string, content string) {
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestDomainPolicyBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domain-policy.yml")
+	writePolicyFile(t, path, `
+blocklist:
+  - evil.example
+  - "*.phish.example"
+  - 're:^login-[a-z]+\.example$'
+  - bücher.example
+`)
+
+	p, err := policy.NewDomainPolicy(path)
+	assert.NoError(t, err)
+
+	blocked := []string{
+		"https://evil.example/",
+		"https://EVIL.example./path",
+		"https://a.phish.example/",
+		"https://a.b.phish.example/",
+		"https://login-bank.example/",
+		"https://xn--bcher-kva.example/",
+	}
+	for _, rawUrl := range blocked {
+		assert.ErrorIs(t, p.Check(rawUrl), policy.ErrDomainBlocked, rawUrl)
+	}
+
+	allowed := []string{
+		"https://notevil.example/",
+		"https://sub.evil.example/",
+		"https://phish.example/",
+		"https://login-bank1.example/",
+		"https://youtu.be/UIbNIhaldLQ",
+	}
+	for _, rawUrl := range allowed {
+		assert.NoError(t, p.Check(rawUrl), rawUrl)
+	}
+}
+
+func TestDomainPolicyAllowlist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domain-policy.yml")
+	writePolicyFile(t, path, `
+blocklist:
+  - private.youtube.com
+allowlist:
+  - youtu.be
+  - "*.youtube.com"
+`)
+
+	p, err := policy.NewDomainPolicy(path)
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.Check("https://youtu.be/UIbNIhaldLQ"))
+	assert.NoError(t, p.Check("https://www.youtube.com/watch?v=dQw4w9WgXcQ"))
+	assert.ErrorIs(t, p.Check("https://private.youtube.com/"), policy.ErrDomainBlocked)
+	assert.ErrorIs(t, p.Check("https://example.com/"), policy.ErrDomainBlocked)
+}
+
+func TestDomainPolicyRejectsInvalidRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domain-policy.yml")
+
+	writePolicyFile(t, path, "blocklist:\n  - 're:('\n")
+	_, err := policy.NewDomainPolicy(path)
+	assert.Error(t, err)
+
+	writePolicyFile(t, path, "blocklist:\n  - 'exa mple.com'\n")
+	_, err = policy.NewDomainPolicy(path)
+	assert.Error(t, err)
+
+	_, err = policy.NewDomainPolicy(filepath.Join(t.TempDir(), "missing.yml"))
+	assert.Error(t, err)
+}
+
+func TestDomainPolicyWatchReloadsChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domain-policy.yml")
+	writePolicyFile(t, path, "blocklist: []\n")
+
+	p, err := policy.NewDomainPolicy(path)
+	assert.NoError(t, err)
+	assert.NoError(t, p.Check("https://evil.example/"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Watch(ctx, 10*time.Millisecond)
+
+	writePolicyFile(t, path, "blocklist:\n  - evil.example\n")
+	assert.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+	assert.Eventually(t, func() bool {
+		return p.Check("https://evil.example/") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	writePolicyFile(t, path, "blocklist:\n  - 're:('\n")
+	assert.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Second)))
+	time.Sleep(50 * time.Millisecond)
+	assert.ErrorIs(t, p.Check("https://evil.example/"), policy.ErrDomainBlocked)
+}
@@ -0,0 +1,39 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+type LimiterI interface {
+	Allow(ctx context.Context, key string, limit Limit) (*Result, error)
+}
+
+// Limit allows Requests per Period, with bursts of up to Requests. A limit
+// without requests is disabled.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// emissionInterval is how often a request is allowed once the burst is used
+// up. It is rounded to milliseconds, the precision of the Redis limiter.
+func (l Limit) emissionInterval() time.Duration {
+	interval := (l.Period / time.Duration(l.Requests)).Truncate(time.Millisecond)
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	return interval
+}
@@ -0,0 +1,94 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/ratelimit"
+)
+
+func runLimiterTests(t *testing.T, newLimiter func(t *testing.T) ratelimit.LimiterI) {
+	t.Run("AllowsBurstThenLimits", func(t *testing.T) {
+		limiter := newLimiter(t)
+		ctx := context.TODO()
+		limit := ratelimit.Limit{Requests: 3, Period: time.Minute}
+
+		for i := 0; i < 3; i++ {
+			result, err := limiter.Allow(ctx, "management:ip:10.0.0.1", limit)
+			assert.NoError(t, err)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, 3, result.Limit)
+			assert.Equal(t, 2-i, result.Remaining)
+		}
+
+		result, err := limiter.Allow(ctx, "management:ip:10.0.0.1", limit)
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+		assert.InDelta(t, 20*time.Second, result.RetryAfter, float64(100*time.Millisecond))
+		assert.InDelta(t, time.Minute, result.ResetAfter, float64(100*time.Millisecond))
+
+		result, err = limiter.Allow(ctx, "management:ip:10.0.0.2", limit)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("RefillsOverTime", func(t *testing.T) {
+		limiter := newLimiter(t)
+		ctx := context.TODO()
+		limit := ratelimit.Limit{Requests: 2, Period: 100 * time.Millisecond}
+
+		for i := 0; i < 2; i++ {
+			result, err := limiter.Allow(ctx, "redirect:ip:10.0.0.1", limit)
+			assert.NoError(t, err)
+			assert.True(t, result.Allowed)
+		}
+		result, err := limiter.Allow(ctx, "redirect:ip:10.0.0.1", limit)
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+
+		time.Sleep(60 * time.Millisecond)
+
+		result, err = limiter.Allow(ctx, "redirect:ip:10.0.0.1", limit)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+	})
+}
+
+func TestRedisLimiter(t *testing.T) {
+	runLimiterTests(t, func(t *testing.T) ratelimit.LimiterI {
+		redisServer := miniredis.RunT(t)
+		return ratelimit.NewRedisLimiter(redis.NewClient(&redis.Options{
+			Addr: redisServer.Addr(),
+		}), "")
+	})
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	runLimiterTests(t, func(t *testing.T) ratelimit.LimiterI {
+		return ratelimit.NewMemoryLimiter()
+	})
+}
+
+func TestRedisLimiterSharesCountersBetweenReplicas(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	first := ratelimit.NewRedisLimiter(redis.NewClient(&redis.Options{Addr: redisServer.Addr()}), "urlblaster:")
+	second := ratelimit.NewRedisLimiter(redis.NewClient(&redis.Options{Addr: redisServer.Addr()}), "urlblaster:")
+	ctx := context.TODO()
+	limit := ratelimit.Limit{Requests: 1, Period: time.Minute}
+
+	result, err := first.Allow(ctx, "management:principal:e0dba740", limit)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	result, err = second.Allow(ctx, "management:principal:e0dba740", limit)
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+
+	assert.True(t, redisServer.Exists("urlblaster:ratelimit:management:principal:e0dba740"))
+	assert.InDelta(t, time.Minute, redisServer.TTL("urlblaster:ratelimit:management:principal:e0dba740"), float64(time.Second))
+}
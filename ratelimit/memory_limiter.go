@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const memorySweepInterval = time.Minute
+
+// MemoryLimiter runs the same algorithm as RedisLimiter in process, for the
+// storage drivers without Redis. Every replica has its own counters.
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	arrivals  map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		arrivals:  map[string]time.Time{},
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	now := time.Now()
+	emission := limit.emissionInterval()
+	period := emission * time.Duration(limit.Requests)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	tat, ok := l.arrivals[key]
+	if !ok || tat.Before(now) {
+		tat = now
+	}
+
+	newTat := tat.Add(emission)
+	if newTat.Sub(now) > period {
+		return &Result{
+			Allowed:    false,
+			Limit:      limit.Requests,
+			RetryAfter: newTat.Sub(now) - period,
+			ResetAfter: tat.Sub(now),
+		}, nil
+	}
+
+	l.arrivals[key] = newTat
+	return &Result{
+		Allowed:    true,
+		Limit:      limit.Requests,
+		Remaining:  int((period - newTat.Sub(now)) / emission),
+		ResetAfter: newTat.Sub(now),
+	}, nil
+}
+
+// sweep forgets the keys whose bucket is full again, like the Redis keys
+// expire. Callers must hold the lock.
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < memorySweepInterval {
+		return
+	}
+
+	for key, tat := range l.arrivals {
+		if tat.Before(now) {
+			delete(l.arrivals, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
+)
+
+// RateLimit limits the requests of every authenticated principal, or client
+// ip for anonymous requests, separately. scope keeps the limits of different
+// route groups apart. When the limiter fails the request is let through.
+func RateLimit(limiter LimiterI, scope string, limit Limit) gin.HandlerFunc {
+	return rateLimit(limiter, limit, func(c *gin.Context) string {
+		if principal, ok := auth.Principal(c); ok {
+			return scope + ":principal:" + principal
+		}
+		return scope + ":ip:" + c.ClientIP()
+	})
+}
+
+// RateLimitByClientIp limits the requests of every client ip, authenticated
+// or not. Put in front of the authentication it also counts the requests
+// with a wrong or missing token, which RateLimit after it never sees.
+func RateLimitByClientIp(limiter LimiterI, scope string, limit Limit) gin.HandlerFunc {
+	return rateLimit(limiter, limit, func(c *gin.Context) string {
+		return scope + ":ip:" + c.ClientIP()
+	})
+}
+
+func rateLimit(limiter LimiterI, limit Limit, requestKey func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limit.Enabled() {
+			c.Next()
+			return
+		}
+
+		key := requestKey(c)
+		result, err := limiter.Allow(c, key, limit)
+		if err != nil {
+			log.Err(err).Msg(fmt.Sprintf("Failed checking rate limit, letting the request through | Error: %v - key: %s", err, key))
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please slow down!"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,143 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/auth"
+	"source.golabs.io/daniel.santoso/url-blaster/ratelimit"
+)
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(ctx context.Context, key string, limit ratelimit.Limit) (*ratelimit.Result, error) {
+	return nil, errors.New("connection refused")
+}
+
+func MockRequest(router *gin.Engine, remoteAddr string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/create-short-url", nil)
+	req.RemoteAddr = remoteAddr
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitAnonymousByClientIp(t *testing.T) {
+	router := gin.New()
+	router.POST("/create-short-url", ratelimit.RateLimit(ratelimit.NewMemoryLimiter(), "management", ratelimit.Limit{Requests: 2, Period: time.Minute}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := MockRequest(router, "10.0.0.1:5000")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("X-RateLimit-Reset"))
+
+	assert.Equal(t, http.StatusOK, MockRequest(router, "10.0.0.1:5001").Code)
+
+	w = MockRequest(router, "10.0.0.1:5002")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	assert.Equal(t, http.StatusOK, MockRequest(router, "10.0.0.2:5000").Code)
+}
+
+func TestRateLimitByPrincipal(t *testing.T) {
+	limiter := ratelimit.NewMemoryLimiter()
+	router := gin.New()
+	router.POST("/create-short-url", func(c *gin.Context) {
+		auth.SetPrincipal(c, c.GetHeader("X-Principal"))
+	}, ratelimit.RateLimit(limiter, "management", ratelimit.Limit{Requests: 1, Period: time.Minute}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(principal, remoteAddr string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/create-short-url", nil)
+		req.Header.Set("X-Principal", principal)
+		req.RemoteAddr = remoteAddr
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("alice", "10.0.0.1:5000"))
+	assert.Equal(t, http.StatusTooManyRequests, request("alice", "10.0.0.2:5000"))
+	assert.Equal(t, http.StatusOK, request("bob", "10.0.0.1:5000"))
+}
+
+func TestRateLimitDisabledOrFailing(t *testing.T) {
+	router := gin.New()
+	router.POST("/create-short-url", ratelimit.RateLimit(failingLimiter{}, "management", ratelimit.Limit{}), ratelimit.RateLimit(failingLimiter{}, "management", ratelimit.Limit{Requests: 1, Period: time.Minute}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for i := 0; i < 3; i++ {
+		w := MockRequest(router, "10.0.0.1:5000")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	}
+}
+
+func TestRateLimitByClientIpBeforeAuthentication(t *testing.T) {
+	limiter := ratelimit.NewMemoryLimiter()
+	router := gin.New()
+	router.POST("/create-short-url", ratelimit.RateLimitByClientIp(limiter, "management-ip", ratelimit.Limit{Requests: 2, Period: time.Minute}), func(c *gin.Context) {
+		principal := c.GetHeader("X-Principal")
+		if principal == "" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		auth.SetPrincipal(c, principal)
+	}, ratelimit.RateLimit(limiter, "management", ratelimit.Limit{Requests: 1, Period: time.Minute}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(principal, remoteAddr string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/create-short-url", nil)
+		req.Header.Set("X-Principal", principal)
+		req.RemoteAddr = remoteAddr
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request("", "10.0.0.1:5000"))
+	assert.Equal(t, http.StatusOK, request("alice", "10.0.0.1:5000"))
+	assert.Equal(t, http.StatusTooManyRequests, request("bob", "10.0.0.1:5000"))
+	assert.Equal(t, http.StatusTooManyRequests, request("alice", "10.0.0.2:5000"))
+	assert.Equal(t, http.StatusOK, request("bob", "10.0.0.2:5000"))
+}
+
+func TestRateLimitByClientIpIgnoresForwardedForFromUntrustedProxies(t *testing.T) {
+	router := gin.New()
+	assert.NoError(t, router.SetTrustedProxies([]string{"10.0.0.9"}))
+	router.POST("/create-short-url", ratelimit.RateLimitByClientIp(ratelimit.NewMemoryLimiter(), "management-ip", ratelimit.Limit{Requests: 2, Period: time.Minute}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(remoteAddr, forwardedFor string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/create-short-url", nil)
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.RemoteAddr = remoteAddr
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("10.0.0.1:5000", "192.168.0.1"))
+	assert.Equal(t, http.StatusOK, request("10.0.0.1:5000", "192.168.0.2"))
+	assert.Equal(t, http.StatusTooManyRequests, request("10.0.0.1:5000", "192.168.0.3"))
+
+	assert.Equal(t, http.StatusOK, request("10.0.0.9:5000", "192.168.0.1"))
+	assert.Equal(t, http.StatusOK, request("10.0.0.9:5000", "192.168.0.1"))
+	assert.Equal(t, http.StatusTooManyRequests, request("10.0.0.9:5000", "192.168.0.1"))
+	assert.Equal(t, http.StatusOK, request("10.0.0.9:5000", "192.168.0.2"))
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const redisKeyPrefix = "ratelimit:"
+
+// gcraScript is the generic cell rate algorithm, a token bucket that only
+// needs to store when the bucket will be full again (the theoretical arrival
+// time). Times are unix milliseconds.
+var gcraScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local emission = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local period = emission * limit
+
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if not tat or tat < now then
+	tat = now
+end
+
+local new_tat = tat + emission
+if new_tat - now > period then
+	return {0, 0, new_tat - now - period, tat - now}
+end
+
+redis.call('SET', KEYS[1], string.format('%d', new_tat), 'PX', string.format('%d', new_tat - now))
+return {1, math.floor((period - (new_tat - now)) / emission), 0, new_tat - now}
+`)
+
+// RedisLimiter shares its counters between every replica using the same
+// Redis.
+type RedisLimiter struct {
+	redisClient redis.UniversalClient
+	keyPrefix   string
+}
+
+func NewRedisLimiter(redisClient redis.UniversalClient, keyPrefix string) *RedisLimiter {
+	return &RedisLimiter{
+		redisClient: redisClient,
+		keyPrefix:   keyPrefix,
+	}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	emission := limit.emissionInterval()
+
+	values, err := gcraScript.Run(ctx, l.redisClient, []string{l.keyPrefix + redisKeyPrefix + key},
+		time.Now().UnixMilli(), emission.Milliseconds(), limit.Requests).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit.Requests,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
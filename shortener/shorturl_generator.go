@@ -11,6 +11,7 @@ import (
 type ShortenerI interface {
 	Base58Encoded(bytes []byte) (string, error)
 	GenerateShortLink(initialUrl string, userId string) (string, error)
+	GenerateSaltedShortLink(initialUrl string, userId string, salt int) (string, error)
 }
 
 type shortener struct {
@@ -36,7 +37,21 @@ func (s *shortener) Base58Encoded(bytes []byte) (string, error) {
 }
 
 func (s *shortener) GenerateShortLink(initialUrl string, userId string) (string, error) {
-	urlHashBytes := hashSHA256(initialUrl + userId)
+	return s.generateFromSeed(initialUrl + userId)
+}
+
+// GenerateSaltedShortLink gives a different short link for every salt, to be
+// used when the unsalted one collides with another url. Salt 0 gives the same
+// short link as GenerateShortLink.
+func (s *shortener) GenerateSaltedShortLink(initialUrl string, userId string, salt int) (string, error) {
+	if salt == 0 {
+		return s.GenerateShortLink(initialUrl, userId)
+	}
+	return s.generateFromSeed(fmt.Sprintf("%s%s#%d", initialUrl, userId, salt))
+}
+
+func (s *shortener) generateFromSeed(seed string) (string, error) {
+	urlHashBytes := hashSHA256(seed)
 	generatedNumber := new(big.Int).SetBytes(urlHashBytes).Uint64()
 	finalString, err := s.Base58Encoded([]byte(fmt.Sprintf("%d", generatedNumber)))
 	if err != nil {
@@ -48,3 +48,17 @@ func TestEncodingFail(t *testing.T) {
 	assert.Equal(t, "", finalString)
 	assert.Error(t, err)
 }
+
+func TestSaltedShortLinkGenerator(t *testing.T) {
+	s := shortener.NewShortener()
+
+	initialUrl := "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+	unsalted, err := s.GenerateSaltedShortLink(initialUrl, UserId, 0)
+	assert.Equal(t, "ASzHLChJ", unsalted)
+	assert.NoError(t, err)
+
+	salted, err := s.GenerateSaltedShortLink(initialUrl, UserId, 1)
+	assert.NotEqual(t, unsalted, salted)
+	assert.Len(t, salted, 8)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const apiKeyPrefix = "apikey:"
+
+var ErrApiKeyNotFound = errors.New("api key not found")
+
+// ApiKey only keeps the hash of the key; the key itself is shown once, when
+// it is issued.
+type ApiKey struct {
+	Id        string    `json:"id"`
+	Hash      string    `json:"hash"`
+	Principal string    `json:"principal"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func apiKeyRedisKey(id string) string {
+	return apiKeyPrefix + id
+}
+
+func encodeApiKey(apiKey ApiKey) (string, error) {
+	value, err := json.Marshal(apiKey)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+func decodeApiKey(value string) (*ApiKey, error) {
+	var apiKey ApiKey
+	if err := json.Unmarshal([]byte(value), &apiKey); err != nil {
+		return nil, err
+	}
+	return &apiKey, nil
+}
@@ -0,0 +1,239 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type CacheStats struct {
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Entries int    `json:"entries"`
+}
+
+// CachedStorageService wraps any StorageServiceI with a bounded in-process LRU
+// for the redirect lookups. Unknown short urls are cached too, for a shorter
+// negativeTtl. Writes through this instance invalidate its cache right away;
+// writes made by other replicas are only seen once the entry's ttl is over.
+type CachedStorageService struct {
+	StorageServiceI
+
+	mu          sync.Mutex
+	entries     map[string]*list.Element
+	recency     *list.List
+	size        int
+	ttl         time.Duration
+	negativeTtl time.Duration
+	generation  uint64
+
+	hits   uint64
+	misses uint64
+}
+
+type cacheEntry struct {
+	shortUrl  string
+	mapping   *UrlMapping
+	expiresAt time.Time
+}
+
+func NewCachedStorageService(storage StorageServiceI, size int, ttl, negativeTtl time.Duration) *CachedStorageService {
+	return &CachedStorageService{
+		StorageServiceI: storage,
+		entries:         map[string]*list.Element{},
+		recency:         list.New(),
+		size:            size,
+		ttl:             ttl,
+		negativeTtl:     negativeTtl,
+	}
+}
+
+func (s *CachedStorageService) CacheStats() CacheStats {
+	s.mu.Lock()
+	entries := len(s.entries)
+	s.mu.Unlock()
+
+	return CacheStats{
+		Hits:    atomic.LoadUint64(&s.hits),
+		Misses:  atomic.LoadUint64(&s.misses),
+		Entries: entries,
+	}
+}
+
+// Uncached reads straight from the wrapped storage, so what it returns is
+// never stale, while its writes still invalidate this instance's cache.
+func (s *CachedStorageService) Uncached() StorageServiceI {
+	return uncachedStorageService{s}
+}
+
+type uncachedStorageService struct {
+	*CachedStorageService
+}
+
+func (s uncachedStorageService) CheckIfShortUrlExists(ctx context.Context, shortUrl string) bool {
+	return s.StorageServiceI.CheckIfShortUrlExists(ctx, shortUrl)
+}
+
+func (s uncachedStorageService) RetrieveUrlMapping(ctx context.Context, shortUrl string) (*UrlMapping, error) {
+	return s.StorageServiceI.RetrieveUrlMapping(ctx, shortUrl)
+}
+
+func (s uncachedStorageService) RetrieveInitialUrl(ctx context.Context, shortUrl string) (string, error) {
+	return s.StorageServiceI.RetrieveInitialUrl(ctx, shortUrl)
+}
+
+func (s *CachedStorageService) CreateUrlMapping(ctx context.Context, shortUrl string, mapping UrlMapping) error {
+	defer s.invalidate(shortUrl)
+	return s.StorageServiceI.CreateUrlMapping(ctx, shortUrl, mapping)
+}
+
+func (s *CachedStorageService) CreateUrlMappings(ctx context.Context, mappings []NewUrlMapping) []error {
+	defer func() {
+		for _, mapping := range mappings {
+			s.invalidate(mapping.ShortUrl)
+		}
+	}()
+	return s.StorageServiceI.CreateUrlMappings(ctx, mappings)
+}
+
+func (s *CachedStorageService) SaveUrlMapping(ctx context.Context, shortUrl string, mapping UrlMapping) error {
+	defer s.invalidate(shortUrl)
+	return s.StorageServiceI.SaveUrlMapping(ctx, shortUrl, mapping)
+}
+
+func (s *CachedStorageService) DeleteUrlMapping(ctx context.Context, shortUrl string) error {
+	defer s.invalidate(shortUrl)
+	return s.StorageServiceI.DeleteUrlMapping(ctx, shortUrl)
+}
+
+// PurgeDeletedUrlMappings also drops the purged mappings this instance has
+// cached.
+func (s *CachedStorageService) PurgeDeletedUrlMappings(ctx context.Context, deletedBefore time.Time) ([]string, error) {
+	defer s.invalidateDeleted(deletedBefore)
+	return s.StorageServiceI.PurgeDeletedUrlMappings(ctx, deletedBefore)
+}
+
+func (s *CachedStorageService) CheckIfShortUrlExists(ctx context.Context, shortUrl string) bool {
+	_, err := s.RetrieveUrlMapping(ctx, shortUrl)
+	return !errors.Is(err, ErrUrlNotFound)
+}
+
+func (s *CachedStorageService) RetrieveUrlMapping(ctx context.Context, shortUrl string) (*UrlMapping, error) {
+	mapping, found, generation := s.lookup(shortUrl)
+	if found {
+		atomic.AddUint64(&s.hits, 1)
+		if mapping == nil {
+			return nil, ErrUrlNotFound
+		}
+		clone := cloneUrlMapping(*mapping)
+		return &clone, nil
+	}
+	atomic.AddUint64(&s.misses, 1)
+
+	mapping, err := s.StorageServiceI.RetrieveUrlMapping(ctx, shortUrl)
+	if errors.Is(err, ErrUrlNotFound) {
+		s.store(shortUrl, nil, s.negativeTtl, generation)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	clone := cloneUrlMapping(*mapping)
+	s.store(shortUrl, &clone, s.ttl, generation)
+	return mapping, nil
+}
+
+func (s *CachedStorageService) RetrieveInitialUrl(ctx context.Context, shortUrl string) (string, error) {
+	mapping, err := s.RetrieveUrlMapping(ctx, shortUrl)
+	if err != nil {
+		return "", err
+	}
+
+	if mapping.IsDeleted() {
+		return "", ErrUrlDeleted
+	}
+	if mapping.IsExpired(time.Now()) {
+		return "", ErrUrlExpired
+	}
+	return mapping.OriginalUrl, nil
+}
+
+// lookup also returns the current generation, which has to be handed back to
+// store so a value read before an invalidation can't be cached after it.
+func (s *CachedStorageService) lookup(shortUrl string) (*UrlMapping, bool, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.entries[shortUrl]
+	if !ok {
+		return nil, false, s.generation
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		s.remove(element)
+		return nil, false, s.generation
+	}
+
+	s.recency.MoveToFront(element)
+	return entry.mapping, true, s.generation
+}
+
+func (s *CachedStorageService) store(shortUrl string, mapping *UrlMapping, ttl time.Duration, generation uint64) {
+	if s.size <= 0 || ttl <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if generation != s.generation {
+		return
+	}
+
+	if element, ok := s.entries[shortUrl]; ok {
+		s.remove(element)
+	}
+
+	s.entries[shortUrl] = s.recency.PushFront(&cacheEntry{
+		shortUrl:  shortUrl,
+		mapping:   mapping,
+		expiresAt: time.Now().Add(ttl),
+	})
+
+	for s.recency.Len() > s.size {
+		s.remove(s.recency.Back())
+	}
+}
+
+func (s *CachedStorageService) invalidate(shortUrl string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.generation++
+	if element, ok := s.entries[shortUrl]; ok {
+		s.remove(element)
+	}
+}
+
+func (s *CachedStorageService) invalidateDeleted(deletedBefore time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.generation++
+	for _, element := range s.entries {
+		mapping := element.Value.(*cacheEntry).mapping
+		if mapping != nil && mapping.IsDeleted() && !mapping.DeletedAt.After(deletedBefore) {
+			s.remove(element)
+		}
+	}
+}
+
+func (s *CachedStorageService) remove(element *list.Element) {
+	s.recency.Remove(element)
+	delete(s.entries, element.Value.(*cacheEntry).shortUrl)
+}
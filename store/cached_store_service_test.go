@@ -0,0 +1,140 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func TestCachedRetrieveInitialUrlHit(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	storageService := store.NewCachedStorageService(&store.StorageService{RedisClient: redisClient}, 10, time.Minute, time.Minute)
+
+	initialUrl := "https://www.guru3d.com/news-story/spotted-ryzen-threadripper-pro-3995wx-processor-with-8-channel-ddr4,2.html"
+	shortUrl := "Jsz4k57oAX"
+	redisClient.Set(ctx, shortUrl, initialUrl, CacheDuration)
+
+	retrievedUrl, err := storageService.RetrieveInitialUrl(ctx, shortUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, initialUrl, retrievedUrl)
+
+	redisServer.SetError("REDISDOWN")
+	retrievedUrl, err = storageService.RetrieveInitialUrl(ctx, shortUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, initialUrl, retrievedUrl)
+
+	assert.Equal(t, store.CacheStats{Hits: 1, Misses: 1, Entries: 1}, storageService.CacheStats())
+}
+
+func TestCachedRetrieveInitialUrlNegativeCaching(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	storageService := store.NewCachedStorageService(&store.StorageService{RedisClient: redisClient}, 10, time.Minute, time.Minute)
+
+	_, err := storageService.RetrieveInitialUrl(ctx, "awokawok")
+	assert.ErrorIs(t, err, store.ErrUrlNotFound)
+
+	redisClient.Set(ctx, "awokawok", "https://youtu.be/8LhMu4bQTQU", CacheDuration)
+	_, err = storageService.RetrieveInitialUrl(ctx, "awokawok")
+	assert.ErrorIs(t, err, store.ErrUrlNotFound)
+	assert.Equal(t, uint64(1), storageService.CacheStats().Hits)
+}
+
+func TestCachedSaveUrlMappingInvalidates(t *testing.T) {
+	ctx := context.TODO()
+	storageService := store.NewCachedStorageService(store.NewMemoryStorageService(), 10, time.Minute, time.Minute)
+
+	shortUrl := "Jsz4k57oAX"
+	_, err := storageService.RetrieveInitialUrl(ctx, shortUrl)
+	assert.ErrorIs(t, err, store.ErrUrlNotFound)
+
+	err = storageService.CreateUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: "https://youtu.be/8LhMu4bQTQU"})
+	assert.NoError(t, err)
+	retrievedUrl, err := storageService.RetrieveInitialUrl(ctx, shortUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://youtu.be/8LhMu4bQTQU", retrievedUrl)
+
+	err = storageService.SaveUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: "https://youtu.be/UIbNIhaldLQ"})
+	assert.NoError(t, err)
+	retrievedUrl, err = storageService.RetrieveInitialUrl(ctx, shortUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://youtu.be/UIbNIhaldLQ", retrievedUrl)
+
+	err = storageService.DeleteUrlMapping(ctx, shortUrl)
+	assert.NoError(t, err)
+	_, err = storageService.RetrieveInitialUrl(ctx, shortUrl)
+	assert.ErrorIs(t, err, store.ErrUrlNotFound)
+}
+
+func TestCachedEntriesExpireAndEvict(t *testing.T) {
+	ctx := context.TODO()
+	backend := store.NewMemoryStorageService()
+	storageService := store.NewCachedStorageService(backend, 2, 50*time.Millisecond, time.Minute)
+
+	for _, shortUrl := range []string{"one", "two", "three"} {
+		assert.NoError(t, backend.SaveUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: "https://youtu.be/" + shortUrl}))
+		_, err := storageService.RetrieveInitialUrl(ctx, shortUrl)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, storageService.CacheStats().Entries)
+
+	assert.NoError(t, backend.SaveUrlMapping(ctx, "three", store.UrlMapping{OriginalUrl: "https://youtu.be/changed"}))
+	time.Sleep(60 * time.Millisecond)
+
+	retrievedUrl, err := storageService.RetrieveInitialUrl(ctx, "three")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://youtu.be/changed", retrievedUrl)
+}
+
+func TestCachedRetrieveUrlMappingReturnsCopy(t *testing.T) {
+	ctx := context.TODO()
+	storageService := store.NewCachedStorageService(store.NewMemoryStorageService(), 10, time.Minute, time.Minute)
+
+	shortUrl := "Jsz4k57oAX"
+	assert.NoError(t, storageService.SaveUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: "https://youtu.be/8LhMu4bQTQU"}))
+
+	mapping, err := storageService.RetrieveUrlMapping(ctx, shortUrl)
+	assert.NoError(t, err)
+	mapping.OriginalUrl = "https://youtu.be/UIbNIhaldLQ"
+
+	retrievedUrl, err := storageService.RetrieveInitialUrl(ctx, shortUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://youtu.be/8LhMu4bQTQU", retrievedUrl)
+}
+
+func TestUncachedReadsStorageAndInvalidates(t *testing.T) {
+	ctx := context.TODO()
+	storage := store.NewMemoryStorageService()
+	cache := store.NewCachedStorageService(storage, 10, time.Minute, time.Minute)
+	uncached := cache.Uncached()
+
+	shortUrl := "Jsz4k57oAX"
+	assert.NoError(t, storage.CreateUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: "https://youtu.be/8LhMu4bQTQU"}))
+	_, err := cache.RetrieveInitialUrl(ctx, shortUrl)
+	assert.NoError(t, err)
+
+	assert.NoError(t, storage.SaveUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: "https://youtu.be/UIbNIhaldLQ"}))
+	retrievedUrl, err := uncached.RetrieveInitialUrl(ctx, shortUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://youtu.be/UIbNIhaldLQ", retrievedUrl)
+	assert.Equal(t, store.CacheStats{Hits: 0, Misses: 1, Entries: 1}, cache.CacheStats())
+
+	assert.NoError(t, uncached.SaveUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: "https://youtu.be/dQw4w9WgXcQ"}))
+	retrievedUrl, err = cache.RetrieveInitialUrl(ctx, shortUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://youtu.be/dQw4w9WgXcQ", retrievedUrl)
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	deletedIndexPrefix = "deleted:"
+	purgeBatch         = 100
+)
+
+// deletedIndexKey is a sorted set of the removed short urls, scored by when
+// they were removed in unix milliseconds, so the reaper doesn't have to scan
+// the keyspace.
+var deletedIndexKey = deletedIndexPrefix + "links"
+
+// indexDeletedUrlMapping keeps the mapping in the index of removed mappings
+// exactly while it is removed.
+func (s *StorageService) indexDeletedUrlMapping(ctx context.Context, shortUrl string, mapping UrlMapping) error {
+	if mapping.IsDeleted() {
+		return s.RedisClient.ZAdd(ctx, s.key(deletedIndexKey), &redis.Z{Score: float64(mapping.DeletedAt.UnixMilli()), Member: shortUrl}).Err()
+	}
+	return s.RedisClient.ZRem(ctx, s.key(deletedIndexKey), shortUrl).Err()
+}
+
+func (s *StorageService) pipelineDeletedIndex(ctx context.Context, pipe redis.Pipeliner, shortUrl string, mapping UrlMapping) redis.Cmder {
+	if mapping.IsDeleted() {
+		return pipe.ZAdd(ctx, s.key(deletedIndexKey), &redis.Z{Score: float64(mapping.DeletedAt.UnixMilli()), Member: shortUrl})
+	}
+	return pipe.ZRem(ctx, s.key(deletedIndexKey), shortUrl)
+}
+
+// PurgeDeletedUrlMappings permanently deletes the mappings removed at or
+// before deletedBefore, together with their history, click statistics and
+// owner index entries, and returns the purged short urls.
+func (s *StorageService) PurgeDeletedUrlMappings(ctx context.Context, deletedBefore time.Time) ([]string, error) {
+	rangeBy := &redis.ZRangeBy{Min: "-inf", Max: strconv.FormatInt(deletedBefore.UnixMilli(), 10), Count: purgeBatch}
+
+	var purged []string
+	for {
+		shortUrls, err := s.RedisClient.ZRangeByScore(ctx, s.key(deletedIndexKey), rangeBy).Result()
+		if err != nil {
+			return purged, err
+		}
+
+		for _, shortUrl := range shortUrls {
+			done, kept, err := s.purgeDeletedUrlMapping(ctx, shortUrl, deletedBefore)
+			if err != nil {
+				return purged, err
+			}
+			if done {
+				purged = append(purged, shortUrl)
+			}
+			if kept {
+				rangeBy.Offset++
+			}
+		}
+
+		if len(shortUrls) < purgeBatch {
+			return purged, nil
+		}
+	}
+}
+
+// purgeDeletedUrlMapping only deletes the mapping when it is still removed,
+// the WATCH makes sure a concurrent restore isn't lost. kept tells whether
+// the entry is left in the index, so the next batch is read past it.
+func (s *StorageService) purgeDeletedUrlMapping(ctx context.Context, shortUrl string, deletedBefore time.Time) (purged, kept bool, err error) {
+	var mapping *UrlMapping
+	err = s.RedisClient.Watch(ctx, func(tx *redis.Tx) error {
+		value, err := tx.Get(ctx, s.key(shortUrl)).Result()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		mapping, err = decodeUrlMapping(value)
+		if err != nil {
+			return err
+		}
+		if !mapping.IsDeleted() || mapping.DeletedAt.After(deletedBefore) {
+			return nil
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, s.key(shortUrl))
+			return nil
+		})
+		return err
+	}, s.key(shortUrl))
+	if err == redis.TxFailedErr {
+		// Changed while being purged, the next run looks at it again.
+		return false, true, nil
+	}
+	if err != nil {
+		return false, true, err
+	}
+
+	if mapping != nil && mapping.IsDeleted() && mapping.DeletedAt.After(deletedBefore) {
+		// Removed again since it was read from the index, its new score is
+		// past this range.
+		return false, false, nil
+	}
+	if mapping != nil && !mapping.IsDeleted() {
+		return false, false, s.RedisClient.ZRem(ctx, s.key(deletedIndexKey), shortUrl).Err()
+	}
+
+	err = s.RedisClient.Del(ctx, s.key(historyRedisKey(shortUrl))).Err()
+	if err != nil {
+		return false, true, err
+	}
+	err = s.deleteStats(ctx, shortUrl)
+	if err != nil {
+		return false, true, err
+	}
+	if mapping != nil {
+		err = s.unindexUrlMapping(ctx, mapping.Owner, shortUrl)
+		if err != nil {
+			return false, true, err
+		}
+	}
+
+	err = s.RedisClient.ZRem(ctx, s.key(deletedIndexKey), shortUrl).Err()
+	return mapping != nil, err != nil, err
+}
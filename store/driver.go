@@ -0,0 +1,9 @@
+package store
+
+// Values of STORAGE_DRIVER. An empty driver means Redis.
+const (
+	RedisDriver    = "redis"
+	MemoryDriver   = "memory"
+	SqliteDriver   = "sqlite"
+	PostgresDriver = "postgres"
+)
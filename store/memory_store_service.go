@@ -0,0 +1,270 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStorageService keeps the mappings in the process memory, for local
+// development and demos where no Redis is available. Everything is lost when
+// the process stops.
+type MemoryStorageService struct {
+	mu       sync.RWMutex
+	mappings map[string]UrlMapping
+	apiKeys  map[string]ApiKey
+	history  map[string][]UrlMappingVersion
+}
+
+func NewMemoryStorageService() *MemoryStorageService {
+	return &MemoryStorageService{
+		mappings: map[string]UrlMapping{},
+		apiKeys:  map[string]ApiKey{},
+		history:  map[string][]UrlMappingVersion{},
+	}
+}
+
+func (s *MemoryStorageService) CreateUrlMapping(ctx context.Context, shortUrl string, mapping UrlMapping) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.lookup(shortUrl, time.Now())
+	if ok && !existing.IsExpired(time.Now()) {
+		return ErrShortUrlTaken
+	}
+
+	s.mappings[shortUrl] = cloneUrlMapping(mapping)
+	delete(s.history, shortUrl)
+	return nil
+}
+
+func (s *MemoryStorageService) CreateUrlMappings(ctx context.Context, mappings []NewUrlMapping) []error {
+	return createUrlMappingsOneByOne(ctx, s, mappings)
+}
+
+func (s *MemoryStorageService) SaveUrlMapping(ctx context.Context, shortUrl string, mapping UrlMapping) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mappings[shortUrl] = cloneUrlMapping(mapping)
+	return nil
+}
+
+func (s *MemoryStorageService) CheckIfShortUrlExists(ctx context.Context, shortUrl string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.lookup(shortUrl, time.Now())
+	return ok
+}
+
+func (s *MemoryStorageService) RetrieveUrlMapping(ctx context.Context, shortUrl string) (*UrlMapping, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	mapping, ok := s.lookup(shortUrl, time.Now())
+	if !ok {
+		return nil, ErrUrlNotFound
+	}
+
+	clone := cloneUrlMapping(mapping)
+	return &clone, nil
+}
+
+func (s *MemoryStorageService) RetrieveInitialUrl(ctx context.Context, shortUrl string) (string, error) {
+	mapping, err := s.RetrieveUrlMapping(ctx, shortUrl)
+	if err != nil {
+		return "", err
+	}
+
+	if mapping.IsDeleted() {
+		return "", ErrUrlDeleted
+	}
+	if mapping.IsExpired(time.Now()) {
+		return "", ErrUrlExpired
+	}
+	return mapping.OriginalUrl, nil
+}
+
+func (s *MemoryStorageService) DeleteUrlMapping(ctx context.Context, shortUrl string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.mappings, shortUrl)
+	delete(s.history, shortUrl)
+	return nil
+}
+
+func (s *MemoryStorageService) PurgeDeletedUrlMappings(ctx context.Context, deletedBefore time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged []string
+	for shortUrl, mapping := range s.mappings {
+		if mapping.IsDeleted() && !mapping.DeletedAt.After(deletedBefore) {
+			delete(s.mappings, shortUrl)
+			delete(s.history, shortUrl)
+			purged = append(purged, shortUrl)
+		}
+	}
+	return purged, nil
+}
+
+// ScanUrlMappings goes through a copy of the mappings in short url order, so
+// fn can use the storage.
+func (s *MemoryStorageService) ScanUrlMappings(ctx context.Context, fn func(ListedUrlMapping) error) error {
+	s.mu.RLock()
+	var listed []ListedUrlMapping
+	now := time.Now()
+	for shortUrl := range s.mappings {
+		if mapping, ok := s.lookup(shortUrl, now); ok {
+			listed = append(listed, ListedUrlMapping{ShortUrl: shortUrl, UrlMapping: cloneUrlMapping(mapping)})
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(listed, func(i, j int) bool {
+		return listed[i].ShortUrl < listed[j].ShortUrl
+	})
+	for _, mapping := range listed {
+		if err := fn(mapping); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStorageService) SaveApiKey(ctx context.Context, apiKey ApiKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apiKeys[apiKey.Id] = apiKey
+	return nil
+}
+
+func (s *MemoryStorageService) RetrieveApiKey(ctx context.Context, id string) (*ApiKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	apiKey, ok := s.apiKeys[id]
+	if !ok {
+		return nil, ErrApiKeyNotFound
+	}
+	return &apiKey, nil
+}
+
+func (s *MemoryStorageService) DeleteApiKey(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.apiKeys[id]; !ok {
+		return ErrApiKeyNotFound
+	}
+	delete(s.apiKeys, id)
+	return nil
+}
+
+func (s *MemoryStorageService) ListUrlMappings(ctx context.Context, owner string, query ListQuery) (*UrlMappingPage, error) {
+	cursor, err := decodeListCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	var listed []ListedUrlMapping
+	now := time.Now()
+	for shortUrl := range s.mappings {
+		mapping, ok := s.lookup(shortUrl, now)
+		if !ok || owner == "" || mapping.Owner != owner || !query.matches(mapping) {
+			continue
+		}
+		if query.after(cursor, query.sortKey(mapping), shortUrl) {
+			listed = append(listed, ListedUrlMapping{ShortUrl: shortUrl, UrlMapping: cloneUrlMapping(mapping)})
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(listed, func(i, j int) bool {
+		a, b := query.sortKey(listed[i].UrlMapping), query.sortKey(listed[j].UrlMapping)
+		if a == b {
+			return (listed[i].ShortUrl < listed[j].ShortUrl) == query.Ascending
+		}
+		return (a < b) == query.Ascending
+	})
+
+	page := &UrlMappingPage{Mappings: listed}
+	if limit := query.limit(); len(listed) > limit {
+		page.Mappings = listed[:limit]
+		last := page.Mappings[limit-1]
+		page.NextCursor = encodeListCursor(query.sortKey(last.UrlMapping), last.ShortUrl)
+	}
+	return page, nil
+}
+
+func (s *MemoryStorageService) AddUrlMappingVersion(ctx context.Context, shortUrl string, version UrlMappingVersion) (*UrlMappingVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions := s.history[shortUrl]
+	version.Version = 1
+	if len(versions) > 0 {
+		version.Version = versions[len(versions)-1].Version + 1
+	}
+
+	versions = append(versions, version)
+	if len(versions) > MaxUrlMappingVersions {
+		versions = versions[len(versions)-MaxUrlMappingVersions:]
+	}
+	s.history[shortUrl] = versions
+	return &version, nil
+}
+
+func (s *MemoryStorageService) ListUrlMappingVersions(ctx context.Context, shortUrl string) ([]UrlMappingVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.lookup(shortUrl, time.Now()); !ok {
+		return []UrlMappingVersion{}, nil
+	}
+
+	versions := s.history[shortUrl]
+	listed := make([]UrlMappingVersion, len(versions))
+	for i, version := range versions {
+		listed[len(versions)-1-i] = version
+	}
+	return listed, nil
+}
+
+// lookup treats mappings past their retention like Redis treats keys past
+// their TTL. Callers must hold the lock.
+func (s *MemoryStorageService) lookup(shortUrl string, now time.Time) (UrlMapping, bool) {
+	mapping, ok := s.mappings[shortUrl]
+	if !ok {
+		return UrlMapping{}, false
+	}
+
+	if mapping.isGone(now) {
+		return UrlMapping{}, false
+	}
+	return mapping, true
+}
+
+func cloneUrlMapping(mapping UrlMapping) UrlMapping {
+	mapping.ExpiresAt = cloneTime(mapping.ExpiresAt)
+	mapping.CreatedAt = cloneTime(mapping.CreatedAt)
+	mapping.UpdatedAt = cloneTime(mapping.UpdatedAt)
+	mapping.DeletedAt = cloneTime(mapping.DeletedAt)
+	if mapping.Tags != nil {
+		mapping.Tags = append([]string{}, mapping.Tags...)
+	}
+	return mapping
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	clone := *t
+	return &clone
+}
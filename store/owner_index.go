@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ownerIndexPrefix = "user:"
+	listScanBatch    = 100
+	maxListScan      = 1000
+)
+
+// ownerIndexKey is a sorted set of the short urls of owner, scored by the
+// sort field in unix milliseconds. Both indexes of an owner share a hash slot.
+func ownerIndexKey(owner, sortBy string) string {
+	return ownerIndexPrefix + "{" + owner + "}:links:" + sortBy
+}
+
+func (s *StorageService) indexUrlMapping(ctx context.Context, shortUrl string, mapping UrlMapping) error {
+	if mapping.Owner == "" {
+		return nil
+	}
+
+	_, err := s.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		s.pipelineOwnerIndex(ctx, pipe, shortUrl, mapping)
+		return nil
+	})
+	return err
+}
+
+func (s *StorageService) pipelineOwnerIndex(ctx context.Context, pipe redis.Pipeliner, shortUrl string, mapping UrlMapping) []redis.Cmder {
+	var cmds []redis.Cmder
+	for _, sortBy := range []string{SortByCreatedAt, SortByUpdatedAt} {
+		score := ListQuery{SortBy: sortBy}.sortKey(mapping)
+		cmds = append(cmds, pipe.ZAdd(ctx, s.key(ownerIndexKey(mapping.Owner, sortBy)), &redis.Z{Score: float64(score), Member: shortUrl}))
+	}
+	return cmds
+}
+
+func (s *StorageService) unindexUrlMapping(ctx context.Context, owner string, shortUrls ...string) error {
+	if owner == "" || len(shortUrls) == 0 {
+		return nil
+	}
+
+	members := make([]interface{}, len(shortUrls))
+	for i, shortUrl := range shortUrls {
+		members[i] = shortUrl
+	}
+
+	_, err := s.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, s.key(ownerIndexKey(owner, SortByCreatedAt)), members...)
+		pipe.ZRem(ctx, s.key(ownerIndexKey(owner, SortByUpdatedAt)), members...)
+		return nil
+	})
+	return err
+}
+
+// ListUrlMappings walks the owner index in batches. The index can lag behind
+// the mappings, when a mapping expired or was taken over by someone else after
+// expiring, so every entry is checked against its mapping and stale entries
+// are dropped. At most maxListScan entries are looked at per page, so a
+// filter matching few mappings can give short pages.
+func (s *StorageService) ListUrlMappings(ctx context.Context, owner string, query ListQuery) (*UrlMappingPage, error) {
+	cursor, err := decodeListCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	sortBy := SortByCreatedAt
+	if query.SortBy == SortByUpdatedAt {
+		sortBy = SortByUpdatedAt
+	}
+	key := s.key(ownerIndexKey(owner, sortBy))
+	limit := query.limit()
+
+	rangeBy := &redis.ZRangeBy{Min: "-inf", Max: "+inf", Count: listScanBatch}
+	if cursor != nil && query.Ascending {
+		rangeBy.Min = strconv.FormatInt(cursor.SortKey, 10)
+	} else if cursor != nil {
+		rangeBy.Max = strconv.FormatInt(cursor.SortKey, 10)
+	}
+
+	var stale []string
+	defer func() {
+		if err := s.unindexUrlMapping(ctx, owner, stale...); err != nil {
+			log.Err(err).Msg(fmt.Sprintf("Failed removing stale entries from the owner index | owner: %s", owner))
+		}
+	}()
+
+	page := &UrlMappingPage{}
+	var last *listCursor
+	for scanned := 0; scanned < maxListScan; {
+		var entries []redis.Z
+		if query.Ascending {
+			entries, err = s.RedisClient.ZRangeByScoreWithScores(ctx, key, rangeBy).Result()
+		} else {
+			entries, err = s.RedisClient.ZRevRangeByScoreWithScores(ctx, key, rangeBy).Result()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			return page, nil
+		}
+		rangeBy.Offset += int64(len(entries))
+
+		pipe := s.RedisClient.Pipeline()
+		values := make([]*redis.StringCmd, len(entries))
+		for i, entry := range entries {
+			values[i] = pipe.Get(ctx, s.key(entry.Member.(string)))
+		}
+		_, err = pipe.Exec(ctx)
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+
+		for i, entry := range entries {
+			scanned++
+			shortUrl := entry.Member.(string)
+			sortKey := int64(entry.Score)
+			if !query.after(cursor, sortKey, shortUrl) {
+				continue
+			}
+			position := &listCursor{SortKey: sortKey, ShortUrl: shortUrl}
+
+			value, err := values[i].Result()
+			if err == redis.Nil {
+				stale = append(stale, shortUrl)
+				last = position
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			mapping, err := decodeUrlMapping(value)
+			if err != nil {
+				return nil, err
+			}
+			if mapping.Owner != owner {
+				stale = append(stale, shortUrl)
+				last = position
+				continue
+			}
+
+			if query.matches(*mapping) {
+				if len(page.Mappings) == limit {
+					page.NextCursor = encodeListCursor(last.SortKey, last.ShortUrl)
+					return page, nil
+				}
+				page.Mappings = append(page.Mappings, ListedUrlMapping{ShortUrl: shortUrl, UrlMapping: *mapping})
+			}
+			last = position
+		}
+	}
+
+	if last != nil {
+		page.NextCursor = encodeListCursor(last.SortKey, last.ShortUrl)
+	}
+	return page, nil
+}
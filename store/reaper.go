@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const DefaultReaperInterval = time.Hour
+
+// Reaper purges the removed mappings once their retention is over. Several
+// replicas can run one against the same storage, a purge is idempotent.
+type Reaper struct {
+	storage   StorageServiceI
+	retention time.Duration
+	interval  time.Duration
+	onPurged  func(ctx context.Context, shortUrl string) error
+}
+
+type ReaperOption func(*Reaper)
+
+// WithPurgeListener calls onPurged for every purged short url, to drop what
+// is kept about it outside of the storage, like its click statistics.
+func WithPurgeListener(onPurged func(ctx context.Context, shortUrl string) error) ReaperOption {
+	return func(r *Reaper) {
+		r.onPurged = onPurged
+	}
+}
+
+func NewReaper(storage StorageServiceI, retention, interval time.Duration, opts ...ReaperOption) *Reaper {
+	if retention <= 0 {
+		retention = DefaultDeletedUrlRetention
+	}
+	if interval <= 0 {
+		interval = DefaultReaperInterval
+	}
+	r := &Reaper{
+		storage:   storage,
+		retention: retention,
+		interval:  interval,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run purges right away and then every interval, until ctx is done.
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.reap(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reap purges the mappings removed longer than the retention ago. A failing
+// purge listener is only logged, the mappings are gone already.
+func (r *Reaper) Reap(ctx context.Context) (int64, error) {
+	purged, err := r.storage.PurgeDeletedUrlMappings(ctx, time.Now().Add(-r.retention))
+	if r.onPurged != nil {
+		for _, shortUrl := range purged {
+			if err := r.onPurged(ctx, shortUrl); err != nil {
+				log.Err(err).Msg(fmt.Sprintf("Failed cleaning up after purged url mapping | Error: %v - shortUrl: %s", err, shortUrl))
+			}
+		}
+	}
+	return int64(len(purged)), err
+}
+
+func (r *Reaper) reap(ctx context.Context) {
+	purged, err := r.Reap(ctx)
+	if err != nil {
+		log.Err(err).Msg(fmt.Sprintf("Failed purging removed url mappings | Error: %v - purged: %d", err, purged))
+		return
+	}
+	if purged > 0 {
+		log.Info().Msg(fmt.Sprintf("Purged removed url mappings | purged: %d", purged))
+	}
+}
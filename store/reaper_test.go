@@ -0,0 +1,29 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func TestReaperPurgesAfterRetention(t *testing.T) {
+	storage := store.NewMemoryStorageService()
+	ctx := context.TODO()
+
+	removedAt := time.Now().Add(-90 * time.Minute)
+	justRemovedAt := time.Now().Add(-time.Minute)
+	assert.NoError(t, storage.SaveUrlMapping(ctx, "cosmos", store.UrlMapping{OriginalUrl: "https://ultra.fandom.com/wiki/Ultraman_Cosmos", DeletedAt: &removedAt}))
+	assert.NoError(t, storage.SaveUrlMapping(ctx, "dyna", store.UrlMapping{OriginalUrl: "https://ultra.fandom.com/wiki/Ultraman_Dyna", DeletedAt: &justRemovedAt}))
+	assert.NoError(t, storage.SaveUrlMapping(ctx, "gaia", store.UrlMapping{OriginalUrl: "https://ultra.fandom.com/wiki/Ultraman_Gaia"}))
+
+	purged, err := store.NewReaper(storage, time.Hour, time.Minute).Reap(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	assert.False(t, storage.CheckIfShortUrlExists(ctx, "cosmos"))
+	assert.True(t, storage.CheckIfShortUrlExists(ctx, "dyna"))
+	assert.True(t, storage.CheckIfShortUrlExists(ctx, "gaia"))
+}
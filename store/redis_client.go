@@ -0,0 +1,105 @@
+package store
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"source.golabs.io/daniel.santoso/url-blaster/config"
+)
+
+// Values of REDIS_MODE. An empty mode means a single Redis node.
+const (
+	RedisStandaloneMode = "standalone"
+	RedisClusterMode    = "cluster"
+	RedisSentinelMode   = "sentinel"
+)
+
+// NewRedisClient builds the Redis client described by the config without
+// connecting to it yet. REDIS_ADDRESSES lists the cluster seed nodes or the
+// sentinels; when it is empty STORAGE_HOST and STORAGE_PORT are used.
+func NewRedisClient(cfg *config.Config) (redis.UniversalClient, error) {
+	addresses := redisAddresses(cfg)
+
+	var tlsConfig *tls.Config
+	if cfg.RedisTlsEnabled {
+		tlsConfig = &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: cfg.RedisTlsInsecureSkipVerify,
+		}
+	}
+
+	switch cfg.RedisMode {
+	case RedisStandaloneMode, "":
+		if len(addresses) != 1 {
+			return nil, fmt.Errorf("standalone redis needs exactly one address, got %d", len(addresses))
+		}
+		return redis.NewClient(&redis.Options{
+			Addr:         addresses[0],
+			Username:     cfg.RedisUsername,
+			Password:     cfg.RedisPassword,
+			DB:           cfg.RedisDb,
+			TLSConfig:    tlsConfig,
+			PoolSize:     cfg.RedisPoolSize,
+			MinIdleConns: cfg.RedisMinIdleConns,
+		}), nil
+	case RedisClusterMode:
+		if cfg.RedisDb != 0 {
+			return nil, fmt.Errorf("redis cluster only supports db 0, got %d", cfg.RedisDb)
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        addresses,
+			Username:     cfg.RedisUsername,
+			Password:     cfg.RedisPassword,
+			TLSConfig:    tlsConfig,
+			PoolSize:     cfg.RedisPoolSize,
+			MinIdleConns: cfg.RedisMinIdleConns,
+		}), nil
+	case RedisSentinelMode:
+		if cfg.RedisMasterName == "" {
+			return nil, fmt.Errorf("redis sentinel needs REDIS_MASTER_NAME")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.RedisMasterName,
+			SentinelAddrs:    addresses,
+			SentinelPassword: cfg.RedisSentinelPassword,
+			Username:         cfg.RedisUsername,
+			Password:         cfg.RedisPassword,
+			DB:               cfg.RedisDb,
+			TLSConfig:        tlsConfig,
+			PoolSize:         cfg.RedisPoolSize,
+			MinIdleConns:     cfg.RedisMinIdleConns,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown redis mode: %s", cfg.RedisMode)
+	}
+}
+
+// RedisKeyPrefix is the namespace of the keys written to Redis, REDIS_KEY_PREFIX
+// or else APP_NAME, followed by a colon.
+func RedisKeyPrefix(cfg *config.Config) string {
+	prefix := cfg.RedisKeyPrefix
+	if prefix == "" {
+		prefix = cfg.AppName
+	}
+	if prefix == "" || strings.HasSuffix(prefix, ":") {
+		return prefix
+	}
+	return prefix + ":"
+}
+
+func redisAddresses(cfg *config.Config) []string {
+	if cfg.RedisAddresses == "" {
+		return []string{fmt.Sprintf("%s:%s", cfg.StorageHost, cfg.StoragePort)}
+	}
+
+	var addresses []string
+	for _, address := range strings.Split(cfg.RedisAddresses, ",") {
+		address = strings.TrimSpace(address)
+		if address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
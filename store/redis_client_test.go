@@ -0,0 +1,100 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"source.golabs.io/daniel.santoso/url-blaster/config"
+	"source.golabs.io/daniel.santoso/url-blaster/store"
+)
+
+func TestNewRedisClientStandalone(t *testing.T) {
+	cfg := &config.Config{
+		StorageHost:     "localhost",
+		StoragePort:     "6379",
+		RedisUsername:   "blaster",
+		RedisPassword:   "secret",
+		RedisDb:         2,
+		RedisTlsEnabled: true,
+		RedisPoolSize:   20,
+	}
+
+	redisClient, err := store.NewRedisClient(cfg)
+	assert.NoError(t, err)
+
+	client, ok := redisClient.(*redis.Client)
+	assert.True(t, ok)
+	assert.Equal(t, "localhost:6379", client.Options().Addr)
+	assert.Equal(t, "blaster", client.Options().Username)
+	assert.Equal(t, "secret", client.Options().Password)
+	assert.Equal(t, 2, client.Options().DB)
+	assert.Equal(t, 20, client.Options().PoolSize)
+	assert.NotNil(t, client.Options().TLSConfig)
+}
+
+func TestNewRedisClientCluster(t *testing.T) {
+	cfg := &config.Config{
+		RedisMode:      store.RedisClusterMode,
+		RedisAddresses: "redis-cluster:7000, redis-cluster:7001,redis-cluster:7002",
+	}
+
+	redisClient, err := store.NewRedisClient(cfg)
+	assert.NoError(t, err)
+
+	client, ok := redisClient.(*redis.ClusterClient)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"redis-cluster:7000", "redis-cluster:7001", "redis-cluster:7002"}, client.Options().Addrs)
+	assert.Nil(t, client.Options().TLSConfig)
+}
+
+func TestNewRedisClientClusterWithDb(t *testing.T) {
+	cfg := &config.Config{
+		RedisMode:      store.RedisClusterMode,
+		RedisAddresses: "redis-cluster:7000",
+		RedisDb:        1,
+	}
+
+	_, err := store.NewRedisClient(cfg)
+	assert.Error(t, err)
+}
+
+func TestNewRedisClientSentinel(t *testing.T) {
+	cfg := &config.Config{
+		RedisMode:       store.RedisSentinelMode,
+		RedisAddresses:  "sentinel-1:26379,sentinel-2:26379",
+		RedisMasterName: "mymaster",
+	}
+
+	redisClient, err := store.NewRedisClient(cfg)
+	assert.NoError(t, err)
+	assert.NotNil(t, redisClient)
+}
+
+func TestNewRedisClientSentinelWithoutMasterName(t *testing.T) {
+	cfg := &config.Config{
+		RedisMode:      store.RedisSentinelMode,
+		RedisAddresses: "sentinel-1:26379",
+	}
+
+	_, err := store.NewRedisClient(cfg)
+	assert.Error(t, err)
+}
+
+func TestNewRedisClientUnknownMode(t *testing.T) {
+	cfg := &config.Config{
+		RedisMode:   "replicated",
+		StorageHost: "localhost",
+		StoragePort: "6379",
+	}
+
+	_, err := store.NewRedisClient(cfg)
+	assert.Error(t, err)
+}
+
+func TestRedisKeyPrefix(t *testing.T) {
+	assert.Equal(t, "urlblaster:", store.RedisKeyPrefix(&config.Config{AppName: "urlblaster"}))
+	assert.Equal(t, "staging:", store.RedisKeyPrefix(&config.Config{AppName: "urlblaster", RedisKeyPrefix: "staging"}))
+	assert.Equal(t, "staging:", store.RedisKeyPrefix(&config.Config{RedisKeyPrefix: "staging:"}))
+	assert.Equal(t, "", store.RedisKeyPrefix(&config.Config{}))
+}
@@ -0,0 +1,608 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+const defaultSqliteDsn = "url-blaster.db"
+
+const sqlScanBatchSize = 500
+
+// sqlMigrations are applied in order and recorded in schema_migrations, so
+// only ever append to this list. The statements have to work on both SQLite
+// and Postgres; timestamps are unix milliseconds for that reason.
+var sqlMigrations = []string{
+	`CREATE TABLE url_mappings (
+		short_url    VARCHAR(255) NOT NULL,
+		original_url TEXT NOT NULL,
+		owner        VARCHAR(255) NOT NULL DEFAULT '',
+		expires_at   BIGINT,
+		CONSTRAINT url_mappings_short_url_key UNIQUE (short_url)
+	)`,
+	`ALTER TABLE url_mappings ADD COLUMN redirect_type INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE url_mappings ADD COLUMN forward_query BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE url_mappings ADD COLUMN forward_path BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE url_mappings ADD COLUMN query_precedence VARCHAR(16) NOT NULL DEFAULT ''`,
+	`CREATE TABLE api_keys (
+		id         VARCHAR(64) NOT NULL PRIMARY KEY,
+		key_hash   VARCHAR(128) NOT NULL,
+		principal  VARCHAR(255) NOT NULL,
+		created_at BIGINT NOT NULL
+	)`,
+	`ALTER TABLE url_mappings ADD COLUMN created_at BIGINT`,
+	`ALTER TABLE url_mappings ADD COLUMN updated_at BIGINT`,
+	`CREATE INDEX url_mappings_owner_created_at_idx ON url_mappings (owner, (COALESCE(created_at, 0)), short_url)`,
+	`CREATE INDEX url_mappings_owner_updated_at_idx ON url_mappings (owner, (COALESCE(updated_at, 0)), short_url)`,
+	`ALTER TABLE url_mappings ADD COLUMN title TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE url_mappings ADD COLUMN tags TEXT NOT NULL DEFAULT ''`,
+	`CREATE TABLE url_mapping_versions (
+		short_url      VARCHAR(255) NOT NULL,
+		version        BIGINT NOT NULL,
+		old_url        TEXT NOT NULL DEFAULT '',
+		new_url        TEXT NOT NULL,
+		changed_by     VARCHAR(255) NOT NULL DEFAULT '',
+		changed_at     BIGINT NOT NULL,
+		rolled_back_to BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (short_url, version)
+	)`,
+	`ALTER TABLE url_mappings ADD COLUMN deleted_at BIGINT`,
+	`CREATE INDEX url_mappings_deleted_at_idx ON url_mappings (deleted_at)`,
+}
+
+type SqlStorageService struct {
+	DB *sql.DB
+}
+
+// NewSqlStorageService connects to SQLite or Postgres depending on driver and
+// brings the schema up to date. An empty SQLite dsn uses a file in the
+// working directory.
+func NewSqlStorageService(ctx context.Context, driver, dsn string) (*SqlStorageService, error) {
+	var driverName string
+	switch driver {
+	case SqliteDriver:
+		driverName = "sqlite"
+		if dsn == "" {
+			dsn = defaultSqliteDsn
+		}
+	case PostgresDriver:
+		driverName = "postgres"
+	default:
+		return nil, fmt.Errorf("unsupported sql driver: %s", driver)
+	}
+
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if driver == SqliteDriver {
+		// SQLite only allows one writer at a time, and every connection to
+		// an in-memory database would otherwise get its own empty database.
+		db.SetMaxOpenConns(1)
+	}
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &SqlStorageService{DB: db}
+	err = s.migrate(ctx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *SqlStorageService) migrate(ctx context.Context) error {
+	_, err := s.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	err = s.DB.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	for version := current + 1; version <= len(sqlMigrations); version++ {
+		err = s.applyMigration(ctx, version, sqlMigrations[version-1])
+		if err != nil {
+			return fmt.Errorf("applying migration %d: %w", version, err)
+		}
+	}
+	return nil
+}
+
+func (s *SqlStorageService) applyMigration(ctx context.Context, version int, statement string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, statement)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SqlStorageService) Close() error {
+	return s.DB.Close()
+}
+
+func (s *SqlStorageService) CreateUrlMapping(ctx context.Context, shortUrl string, mapping UrlMapping) error {
+	args := append([]interface{}{shortUrl}, sqlMappingArgs(mapping)...)
+
+	result, err := s.DB.ExecContext(ctx, insertSqlMappingQuery+` ON CONFLICT (short_url) DO NOTHING`, args...)
+	if err != nil {
+		return err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted == 1 {
+		return s.deleteUrlMappingVersions(ctx, shortUrl)
+	}
+
+	// Same as with Redis, an expired mapping can be taken over. The condition
+	// is part of the update so a concurrent create can't be overwritten.
+	query := fmt.Sprintf(`UPDATE url_mappings SET %s
+		WHERE short_url = $1 AND expires_at IS NOT NULL AND expires_at <= $%d`,
+		sqlAssignments(func(i int, column string) string { return fmt.Sprintf("$%d", i+2) }),
+		len(args)+1)
+	result, err = s.DB.ExecContext(ctx, query, append(args, time.Now().UnixMilli())...)
+	if err != nil {
+		return err
+	}
+
+	replaced, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if replaced == 0 {
+		return ErrShortUrlTaken
+	}
+	return s.deleteUrlMappingVersions(ctx, shortUrl)
+}
+
+func (s *SqlStorageService) CreateUrlMappings(ctx context.Context, mappings []NewUrlMapping) []error {
+	return createUrlMappingsOneByOne(ctx, s, mappings)
+}
+
+func (s *SqlStorageService) SaveUrlMapping(ctx context.Context, shortUrl string, mapping UrlMapping) error {
+	query := insertSqlMappingQuery + ` ON CONFLICT (short_url) DO UPDATE SET ` +
+		sqlAssignments(func(i int, column string) string { return "excluded." + column })
+
+	_, err := s.DB.ExecContext(ctx, query, append([]interface{}{shortUrl}, sqlMappingArgs(mapping)...)...)
+	return err
+}
+
+func (s *SqlStorageService) CheckIfShortUrlExists(ctx context.Context, shortUrl string) bool {
+	_, err := s.RetrieveUrlMapping(ctx, shortUrl)
+	return !errors.Is(err, ErrUrlNotFound)
+}
+
+func (s *SqlStorageService) RetrieveUrlMapping(ctx context.Context, shortUrl string) (*UrlMapping, error) {
+	row := s.DB.QueryRowContext(ctx, `SELECT `+strings.Join(sqlMappingColumns, ", ")+` FROM url_mappings WHERE short_url = $1`, shortUrl)
+
+	mapping, err := scanSqlMapping(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrUrlNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if mapping.isGone(time.Now()) {
+		return nil, ErrUrlNotFound
+	}
+	return mapping, nil
+}
+
+func (s *SqlStorageService) RetrieveInitialUrl(ctx context.Context, shortUrl string) (string, error) {
+	mapping, err := s.RetrieveUrlMapping(ctx, shortUrl)
+	if err != nil {
+		return "", err
+	}
+
+	if mapping.IsDeleted() {
+		return "", ErrUrlDeleted
+	}
+	if mapping.IsExpired(time.Now()) {
+		return "", ErrUrlExpired
+	}
+	return mapping.OriginalUrl, nil
+}
+
+func (s *SqlStorageService) DeleteUrlMapping(ctx context.Context, shortUrl string) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM url_mappings WHERE short_url = $1`, shortUrl)
+	if err != nil {
+		return err
+	}
+	return s.deleteUrlMappingVersions(ctx, shortUrl)
+}
+
+func (s *SqlStorageService) PurgeDeletedUrlMappings(ctx context.Context, deletedBefore time.Time) ([]string, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM url_mapping_versions WHERE short_url IN
+		(SELECT short_url FROM url_mappings WHERE deleted_at IS NOT NULL AND deleted_at <= $1)`, deletedBefore.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `DELETE FROM url_mappings WHERE deleted_at IS NOT NULL AND deleted_at <= $1 RETURNING short_url`, deletedBefore.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var purged []string
+	for rows.Next() {
+		var shortUrl string
+		if err := rows.Scan(&shortUrl); err != nil {
+			return nil, err
+		}
+		purged = append(purged, shortUrl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return purged, tx.Commit()
+}
+
+// AddUrlMappingVersion numbers the version in the insert itself. Concurrent
+// changes of the same short url can still pick the same number, the loser
+// then fails on the primary key instead of overwriting a version.
+func (s *SqlStorageService) AddUrlMappingVersion(ctx context.Context, shortUrl string, version UrlMappingVersion) (*UrlMappingVersion, error) {
+	err := s.DB.QueryRowContext(ctx, `INSERT INTO url_mapping_versions (short_url, version, old_url, new_url, changed_by, changed_at, rolled_back_to)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, $5, $6 FROM url_mapping_versions WHERE short_url = $1
+		RETURNING version`,
+		shortUrl, version.OldUrl, version.NewUrl, version.ChangedBy, version.ChangedAt.UnixMilli(), version.RolledBackTo).
+		Scan(&version.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.DB.ExecContext(ctx, `DELETE FROM url_mapping_versions WHERE short_url = $1 AND version <= $2`,
+		shortUrl, version.Version-MaxUrlMappingVersions)
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+func (s *SqlStorageService) ListUrlMappingVersions(ctx context.Context, shortUrl string) ([]UrlMappingVersion, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT version, old_url, new_url, changed_by, changed_at, rolled_back_to
+		FROM url_mapping_versions WHERE short_url = $1 ORDER BY version DESC`, shortUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []UrlMappingVersion{}
+	for rows.Next() {
+		var version UrlMappingVersion
+		var changedAt int64
+		err := rows.Scan(&version.Version, &version.OldUrl, &version.NewUrl, &version.ChangedBy, &changedAt, &version.RolledBackTo)
+		if err != nil {
+			return nil, err
+		}
+		version.ChangedAt = time.UnixMilli(changedAt).UTC()
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+func (s *SqlStorageService) deleteUrlMappingVersions(ctx context.Context, shortUrl string) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM url_mapping_versions WHERE short_url = $1`, shortUrl)
+	return err
+}
+
+func (s *SqlStorageService) SaveApiKey(ctx context.Context, apiKey ApiKey) error {
+	_, err := s.DB.ExecContext(ctx, `INSERT INTO api_keys (id, key_hash, principal, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE SET key_hash = excluded.key_hash, principal = excluded.principal, created_at = excluded.created_at`,
+		apiKey.Id, apiKey.Hash, apiKey.Principal, apiKey.CreatedAt.UnixMilli())
+	return err
+}
+
+func (s *SqlStorageService) RetrieveApiKey(ctx context.Context, id string) (*ApiKey, error) {
+	apiKey := ApiKey{Id: id}
+	var createdAt int64
+
+	err := s.DB.QueryRowContext(ctx, `SELECT key_hash, principal, created_at FROM api_keys WHERE id = $1`, id).
+		Scan(&apiKey.Hash, &apiKey.Principal, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrApiKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey.CreatedAt = time.UnixMilli(createdAt).UTC()
+	return &apiKey, nil
+}
+
+func (s *SqlStorageService) DeleteApiKey(ctx context.Context, id string) error {
+	result, err := s.DB.ExecContext(ctx, `DELETE FROM api_keys WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrApiKeyNotFound
+	}
+	return nil
+}
+
+func (s *SqlStorageService) ListUrlMappings(ctx context.Context, owner string, query ListQuery) (*UrlMappingPage, error) {
+	cursor, err := decodeListCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	sortColumn := "COALESCE(created_at, 0)"
+	if query.SortBy == SortByUpdatedAt {
+		sortColumn = "COALESCE(updated_at, 0)"
+	}
+	order, compare := "DESC", "<"
+	if query.Ascending {
+		order, compare = "ASC", ">"
+	}
+
+	conditions := []string{"owner = $1", "(expires_at IS NULL OR expires_at > $2 OR deleted_at IS NOT NULL)", "deleted_at IS NULL"}
+	if query.Deleted {
+		conditions[2] = "deleted_at IS NOT NULL"
+	}
+	args := []interface{}{owner, time.Now().Add(-ExpiredUrlRetention).UnixMilli()}
+	if query.Contains != "" {
+		args = append(args, "%"+escapeSqlLike(strings.ToLower(query.Contains))+"%")
+		conditions = append(conditions, fmt.Sprintf(`LOWER(original_url) LIKE $%d ESCAPE '\'`, len(args)))
+	}
+	if cursor != nil {
+		args = append(args, cursor.SortKey, cursor.ShortUrl)
+		conditions = append(conditions, fmt.Sprintf("(%[1]s %[2]s $%[3]d OR (%[1]s = $%[3]d AND short_url %[2]s $%[4]d))",
+			sortColumn, compare, len(args)-1, len(args)))
+	}
+
+	limit := query.limit()
+	rows, err := s.DB.QueryContext(ctx, fmt.Sprintf(`SELECT short_url, %s FROM url_mappings WHERE %s ORDER BY %s %s, short_url %s LIMIT %d`,
+		strings.Join(sqlMappingColumns, ", "), strings.Join(conditions, " AND "), sortColumn, order, order, limit+1), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &UrlMappingPage{}
+	for rows.Next() {
+		var shortUrl string
+		mapping, err := scanSqlMapping(prefixedRowScanner{row: rows, prefix: []interface{}{&shortUrl}})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(page.Mappings) == limit {
+			last := page.Mappings[limit-1]
+			page.NextCursor = encodeListCursor(query.sortKey(last.UrlMapping), last.ShortUrl)
+			break
+		}
+		page.Mappings = append(page.Mappings, ListedUrlMapping{ShortUrl: shortUrl, UrlMapping: *mapping})
+	}
+	return page, rows.Err()
+}
+
+// ScanUrlMappings reads the mappings in pages ordered by short url, so fn
+// can use the storage without holding a connection open.
+func (s *SqlStorageService) ScanUrlMappings(ctx context.Context, fn func(ListedUrlMapping) error) error {
+	after := ""
+	for {
+		page, err := s.scanUrlMappingPage(ctx, after)
+		if err != nil {
+			return err
+		}
+
+		for _, mapping := range page {
+			if err := fn(mapping); err != nil {
+				return err
+			}
+		}
+		if len(page) < sqlScanBatchSize {
+			return nil
+		}
+		after = page[len(page)-1].ShortUrl
+	}
+}
+
+func (s *SqlStorageService) scanUrlMappingPage(ctx context.Context, after string) ([]ListedUrlMapping, error) {
+	rows, err := s.DB.QueryContext(ctx, fmt.Sprintf(`SELECT short_url, %s FROM url_mappings WHERE short_url > $1 AND (expires_at IS NULL OR expires_at > $2 OR deleted_at IS NOT NULL) ORDER BY short_url LIMIT %d`,
+		strings.Join(sqlMappingColumns, ", "), sqlScanBatchSize), after, time.Now().Add(-ExpiredUrlRetention).UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var page []ListedUrlMapping
+	for rows.Next() {
+		var shortUrl string
+		mapping, err := scanSqlMapping(prefixedRowScanner{row: rows, prefix: []interface{}{&shortUrl}})
+		if err != nil {
+			return nil, err
+		}
+		page = append(page, ListedUrlMapping{ShortUrl: shortUrl, UrlMapping: *mapping})
+	}
+	return page, rows.Err()
+}
+
+// sqlMappingColumns are the url_mappings columns next to short_url. The values
+// of sqlMappingArgs and the destinations in scanSqlMapping are in this order.
+var sqlMappingColumns = []string{
+	"original_url",
+	"owner",
+	"expires_at",
+	"redirect_type",
+	"forward_query",
+	"forward_path",
+	"query_precedence",
+	"created_at",
+	"updated_at",
+	"title",
+	"tags",
+	"deleted_at",
+}
+
+var insertSqlMappingQuery = fmt.Sprintf(`INSERT INTO url_mappings (short_url, %s) VALUES ($1, %s)`,
+	strings.Join(sqlMappingColumns, ", "),
+	sqlValues(func(i int, column string) string { return fmt.Sprintf("$%d", i+2) }))
+
+func sqlMappingArgs(mapping UrlMapping) []interface{} {
+	return []interface{}{
+		mapping.OriginalUrl,
+		mapping.Owner,
+		toUnixMilli(mapping.ExpiresAt),
+		mapping.RedirectType,
+		mapping.ForwardQuery,
+		mapping.ForwardPath,
+		mapping.QueryPrecedence,
+		toUnixMilli(mapping.CreatedAt),
+		toUnixMilli(mapping.UpdatedAt),
+		mapping.Title,
+		encodeSqlTags(mapping.Tags),
+		toUnixMilli(mapping.DeletedAt),
+	}
+}
+
+type sqlRowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// prefixedRowScanner scans the leading columns of a row into prefix and hands
+// the rest to the caller.
+type prefixedRowScanner struct {
+	row    sqlRowScanner
+	prefix []interface{}
+}
+
+func (s prefixedRowScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(s.prefix, dest...)...)
+}
+
+func scanSqlMapping(row sqlRowScanner) (*UrlMapping, error) {
+	var mapping UrlMapping
+	var expiresAt, createdAt, updatedAt, deletedAt sql.NullInt64
+	var tags string
+
+	err := row.Scan(
+		&mapping.OriginalUrl,
+		&mapping.Owner,
+		&expiresAt,
+		&mapping.RedirectType,
+		&mapping.ForwardQuery,
+		&mapping.ForwardPath,
+		&mapping.QueryPrecedence,
+		&createdAt,
+		&updatedAt,
+		&mapping.Title,
+		&tags,
+		&deletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping.Tags, err = decodeSqlTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping.ExpiresAt = fromUnixMilli(expiresAt)
+	mapping.CreatedAt = fromUnixMilli(createdAt)
+	mapping.UpdatedAt = fromUnixMilli(updatedAt)
+	mapping.DeletedAt = fromUnixMilli(deletedAt)
+	return &mapping, nil
+}
+
+// sqlAssignments gives "column = value, ..." for all sqlMappingColumns.
+func sqlAssignments(value func(i int, column string) string) string {
+	assignments := make([]string, len(sqlMappingColumns))
+	for i, column := range sqlMappingColumns {
+		assignments[i] = column + " = " + value(i, column)
+	}
+	return strings.Join(assignments, ", ")
+}
+
+// sqlValues gives "value, ..." for all sqlMappingColumns.
+func sqlValues(value func(i int, column string) string) string {
+	values := make([]string, len(sqlMappingColumns))
+	for i, column := range sqlMappingColumns {
+		values[i] = value(i, column)
+	}
+	return strings.Join(values, ", ")
+}
+
+func toUnixMilli(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixMilli(), Valid: true}
+}
+
+func fromUnixMilli(value sql.NullInt64) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	t := time.UnixMilli(value.Int64).UTC()
+	return &t
+}
+
+func escapeSqlLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// Tags are kept as a JSON array, empty for no tags.
+func encodeSqlTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	encoded, _ := json.Marshal(tags)
+	return string(encoded)
+}
+
+func decodeSqlTags(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var tags []string
+	err := json.Unmarshal([]byte(value), &tags)
+	return tags, err
+}
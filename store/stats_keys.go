@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+const statsPrefix = "stats:"
+
+// Click statistics by time are kept a day per hourly key and a month per
+// daily key, each key expiring this long after its period is over.
+const (
+	StatsHourlyRetention = 8 * 24 * time.Hour
+	StatsDailyRetention  = 366 * 24 * time.Hour
+)
+
+// StatsKey is one of the click statistics of shortUrl. The hash tag keeps all
+// of them in the same cluster slot, so they can be pipelined and deleted
+// together.
+func StatsKey(shortUrl, name string) string {
+	return statsPrefix + "{" + shortUrl + "}:" + name
+}
+
+// HourlyStatsKey is a hash of the clicks of the day of t, by hour.
+func HourlyStatsKey(shortUrl string, t time.Time) string {
+	return StatsKey(shortUrl, "hourly:"+t.UTC().Format("2006-01-02"))
+}
+
+// DailyStatsKey is a hash of the clicks of the month of t, by day.
+func DailyStatsKey(shortUrl string, t time.Time) string {
+	return StatsKey(shortUrl, "daily:"+t.UTC().Format("2006-01"))
+}
+
+// LegacyDailyStatsKey is the hash of the clicks by day written before they
+// were split by month. It isn't written anymore.
+func LegacyDailyStatsKey(shortUrl string) string {
+	return StatsKey(shortUrl, "daily")
+}
+
+// StatsKeys lists every key the click statistics of shortUrl can be in at
+// now. Time buckets older than their retention have expired already.
+func StatsKeys(shortUrl string, now time.Time) []string {
+	keys := []string{
+		StatsKey(shortUrl, "clicks"),
+		StatsKey(shortUrl, "visitors"),
+		StatsKey(shortUrl, "referrers"),
+		StatsKey(shortUrl, "user_agents"),
+		LegacyDailyStatsKey(shortUrl),
+	}
+
+	now = now.UTC()
+	day := now.Add(-StatsHourlyRetention).AddDate(0, 0, -1)
+	for day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC); !day.After(now); day = day.AddDate(0, 0, 1) {
+		keys = append(keys, HourlyStatsKey(shortUrl, day))
+	}
+	month := now.Add(-StatsDailyRetention).AddDate(0, -1, 0)
+	for month = time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(now); month = month.AddDate(0, 1, 0) {
+		keys = append(keys, DailyStatsKey(shortUrl, month))
+	}
+	return keys
+}
+
+// deleteStats drops the click statistics of shortUrl, so a short url created
+// again later doesn't start with them.
+func (s *StorageService) deleteStats(ctx context.Context, shortUrl string) error {
+	keys := StatsKeys(shortUrl, time.Now())
+	for i, key := range keys {
+		keys[i] = s.key(key)
+	}
+	return s.RedisClient.Del(ctx, keys...).Err()
+}
@@ -2,7 +2,9 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/rs/zerolog/log"
@@ -10,34 +12,50 @@ import (
 )
 
 type StorageServiceI interface {
-	SaveUrlMapping(ctx context.Context, shortUrl, originalUrl string) error
+	CreateUrlMapping(ctx context.Context, shortUrl string, mapping UrlMapping) error
+	CreateUrlMappings(ctx context.Context, mappings []NewUrlMapping) []error
+	SaveUrlMapping(ctx context.Context, shortUrl string, mapping UrlMapping) error
 	CheckIfShortUrlExists(ctx context.Context, shortUrl string) bool
+	RetrieveUrlMapping(ctx context.Context, shortUrl string) (*UrlMapping, error)
 	RetrieveInitialUrl(ctx context.Context, shortUrl string) (string, error)
 	DeleteUrlMapping(ctx context.Context, shortUrl string) error
+
+	SaveApiKey(ctx context.Context, apiKey ApiKey) error
+	RetrieveApiKey(ctx context.Context, id string) (*ApiKey, error)
+	DeleteApiKey(ctx context.Context, id string) error
+
+	ListUrlMappings(ctx context.Context, owner string, query ListQuery) (*UrlMappingPage, error)
+	ScanUrlMappings(ctx context.Context, fn func(ListedUrlMapping) error) error
+
+	AddUrlMappingVersion(ctx context.Context, shortUrl string, version UrlMappingVersion) (*UrlMappingVersion, error)
+	ListUrlMappingVersions(ctx context.Context, shortUrl string) ([]UrlMappingVersion, error)
+
+	PurgeDeletedUrlMappings(ctx context.Context, deletedBefore time.Time) ([]string, error)
 }
 
 type StorageService struct {
 	Cfg         *config.Config
-	RedisClient *redis.Client
+	RedisClient redis.UniversalClient
+	// KeyPrefix namespaces every key written to Redis, so several
+	// environments or services can share one Redis.
+	KeyPrefix string
 }
 
-func NewStorageService(cfg *config.Config, ctx context.Context) StorageServiceI {
+func NewStorageService(cfg *config.Config, ctx context.Context) *StorageService {
 	redisClient := initializeRedis(cfg, ctx)
 
 	return &StorageService{
 		Cfg:         cfg,
 		RedisClient: redisClient,
+		KeyPrefix:   RedisKeyPrefix(cfg),
 	}
 }
 
-func initializeRedis(cfg *config.Config, ctx context.Context) *redis.Client {
-	address := fmt.Sprintf("%s:%s", cfg.StorageHost, cfg.StoragePort)
-
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     address,
-		Password: "",
-		DB:       0,
-	})
+func initializeRedis(cfg *config.Config, ctx context.Context) redis.UniversalClient {
+	redisClient, err := NewRedisClient(cfg)
+	if err != nil {
+		log.Fatal().Msg(fmt.Sprintf("Error init Redis: %v", err))
+	}
 
 	pong, err := redisClient.Ping(ctx).Result()
 	if err != nil {
@@ -48,33 +66,334 @@ func initializeRedis(cfg *config.Config, ctx context.Context) *redis.Client {
 	return redisClient
 }
 
-func (s *StorageService) SaveUrlMapping(ctx context.Context, shortUrl, originalUrl string) error {
-	err := s.RedisClient.Set(ctx, shortUrl, originalUrl, 0).Err()
+func (s *StorageService) key(key string) string {
+	return s.KeyPrefix + key
+}
+
+func (s *StorageService) CreateUrlMapping(ctx context.Context, shortUrl string, mapping UrlMapping) error {
+	if !IsUrlMappingKey(shortUrl) {
+		return ErrReservedKey
+	}
+
+	value, err := encodeUrlMapping(mapping)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	created, err := s.RedisClient.SetNX(ctx, s.key(shortUrl), value, mappingExpiration(mapping)).Result()
+	if err != nil {
+		return err
+	}
+	if !created {
+		err = s.replaceExpiredUrlMapping(ctx, shortUrl, value, mappingExpiration(mapping))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = s.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.key(historyRedisKey(shortUrl)))
+		s.pipelineDeletedIndex(ctx, pipe, shortUrl, mapping)
+		if mapping.Owner != "" {
+			s.pipelineOwnerIndex(ctx, pipe, shortUrl, mapping)
+		}
+		return nil
+	})
+	return err
+}
+
+// CreateUrlMappings writes the whole batch in two pipelines: one creating the
+// mappings and one clearing their history and indexing them. Only short urls
+// found taken go through replaceExpiredUrlMapping one by one.
+func (s *StorageService) CreateUrlMappings(ctx context.Context, mappings []NewUrlMapping) []error {
+	errs := make([]error, len(mappings))
+	values := make([]string, len(mappings))
+	for i, mapping := range mappings {
+		if !IsUrlMappingKey(mapping.ShortUrl) {
+			errs[i] = ErrReservedKey
+			continue
+		}
+		values[i], errs[i] = encodeUrlMapping(mapping.Mapping)
+	}
+
+	pipe := s.RedisClient.Pipeline()
+	created := make([]*redis.BoolCmd, len(mappings))
+	for i, mapping := range mappings {
+		if errs[i] == nil {
+			created[i] = pipe.SetNX(ctx, s.key(mapping.ShortUrl), values[i], mappingExpiration(mapping.Mapping))
+		}
+	}
+	_, _ = pipe.Exec(ctx)
+
+	for i, mapping := range mappings {
+		if created[i] == nil {
+			continue
+		}
+		ok, err := created[i].Result()
+		if err == nil && !ok {
+			err = s.replaceExpiredUrlMapping(ctx, mapping.ShortUrl, values[i], mappingExpiration(mapping.Mapping))
+		}
+		errs[i] = err
+	}
+
+	pipe = s.RedisClient.Pipeline()
+	cmds := make([][]redis.Cmder, len(mappings))
+	for i, mapping := range mappings {
+		if errs[i] != nil {
+			continue
+		}
+		cmds[i] = append(cmds[i],
+			pipe.Del(ctx, s.key(historyRedisKey(mapping.ShortUrl))),
+			pipe.ZRem(ctx, s.key(deletedIndexKey), mapping.ShortUrl))
+		if mapping.Mapping.Owner != "" {
+			cmds[i] = append(cmds[i], s.pipelineOwnerIndex(ctx, pipe, mapping.ShortUrl, mapping.Mapping)...)
+		}
+	}
+	_, _ = pipe.Exec(ctx)
+
+	for i := range mappings {
+		for _, cmd := range cmds[i] {
+			if err := cmd.Err(); err != nil {
+				errs[i] = err
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// replaceExpiredUrlMapping takes over a short url whose mapping has expired but
+// is still kept for the 410 response. The WATCH makes sure a concurrent create
+// of the same short url can't be overwritten.
+func (s *StorageService) replaceExpiredUrlMapping(ctx context.Context, shortUrl, value string, expiration time.Duration) error {
+	err := s.RedisClient.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, s.key(shortUrl)).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+
+		if err == nil {
+			existing, err := decodeUrlMapping(current)
+			if err != nil {
+				return err
+			}
+			if !existing.IsExpired(time.Now()) {
+				return ErrShortUrlTaken
+			}
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, s.key(shortUrl), value, expiration)
+			return nil
+		})
+		return err
+	}, s.key(shortUrl))
+
+	if err == redis.TxFailedErr {
+		return ErrShortUrlTaken
+	}
+	return err
+}
+
+func (s *StorageService) SaveUrlMapping(ctx context.Context, shortUrl string, mapping UrlMapping) error {
+	if !IsUrlMappingKey(shortUrl) {
+		return ErrReservedKey
+	}
+
+	value, err := encodeUrlMapping(mapping)
+	if err != nil {
+		return err
+	}
+
+	err = s.RedisClient.Set(ctx, s.key(shortUrl), value, mappingExpiration(mapping)).Err()
+	if err != nil {
+		return err
+	}
+
+	err = s.indexDeletedUrlMapping(ctx, shortUrl, mapping)
+	if err != nil {
+		return err
+	}
+
+	return s.indexUrlMapping(ctx, shortUrl, mapping)
 }
 
 func (s *StorageService) CheckIfShortUrlExists(ctx context.Context, shortUrl string) bool {
-	_, err := s.RedisClient.Get(ctx, shortUrl).Result()
+	if !IsUrlMappingKey(shortUrl) {
+		return false
+	}
+	_, err := s.RedisClient.Get(ctx, s.key(shortUrl)).Result()
 	return err != redis.Nil
 }
 
+// RetrieveUrlMapping never reads the service's own keys as a mapping, a
+// reserved key is simply not found.
+func (s *StorageService) RetrieveUrlMapping(ctx context.Context, shortUrl string) (*UrlMapping, error) {
+	if !IsUrlMappingKey(shortUrl) {
+		return nil, ErrUrlNotFound
+	}
+	result, err := s.RedisClient.Get(ctx, s.key(shortUrl)).Result()
+	if err == redis.Nil {
+		return nil, ErrUrlNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeUrlMapping(result)
+}
+
 func (s *StorageService) RetrieveInitialUrl(ctx context.Context, shortUrl string) (string, error) {
-	result, err := s.RedisClient.Get(ctx, shortUrl).Result()
+	mapping, err := s.RetrieveUrlMapping(ctx, shortUrl)
 	if err != nil {
 		return "", err
 	}
-	return result, nil
+
+	if mapping.IsDeleted() {
+		return "", ErrUrlDeleted
+	}
+	if mapping.IsExpired(time.Now()) {
+		return "", ErrUrlExpired
+	}
+	return mapping.OriginalUrl, nil
 }
 
 func (s *StorageService) DeleteUrlMapping(ctx context.Context, shortUrl string) error {
-	err := s.RedisClient.Del(ctx, shortUrl).Err()
+	if !IsUrlMappingKey(shortUrl) {
+		return nil
+	}
+
+	mapping, err := s.RetrieveUrlMapping(ctx, shortUrl)
+	if err != nil && !errors.Is(err, ErrUrlNotFound) {
+		return err
+	}
+
+	// Separate DELs, the mapping and its history are in different cluster
+	// slots.
+	err = s.RedisClient.Del(ctx, s.key(shortUrl)).Err()
+	if err != nil {
+		return err
+	}
+
+	err = s.RedisClient.Del(ctx, s.key(historyRedisKey(shortUrl))).Err()
+	if err != nil {
+		return err
+	}
+
+	err = s.deleteStats(ctx, shortUrl)
+	if err != nil {
+		return err
+	}
+
+	err = s.RedisClient.ZRem(ctx, s.key(deletedIndexKey), shortUrl).Err()
 	if err != nil {
 		return err
 	}
 
+	if mapping != nil {
+		return s.unindexUrlMapping(ctx, mapping.Owner, shortUrl)
+	}
+	return nil
+}
+
+func (s *StorageService) SaveApiKey(ctx context.Context, apiKey ApiKey) error {
+	value, err := encodeApiKey(apiKey)
+	if err != nil {
+		return err
+	}
+
+	return s.RedisClient.Set(ctx, s.key(apiKeyRedisKey(apiKey.Id)), value, 0).Err()
+}
+
+func (s *StorageService) RetrieveApiKey(ctx context.Context, id string) (*ApiKey, error) {
+	result, err := s.RedisClient.Get(ctx, s.key(apiKeyRedisKey(id))).Result()
+	if err == redis.Nil {
+		return nil, ErrApiKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeApiKey(result)
+}
+
+func (s *StorageService) DeleteApiKey(ctx context.Context, id string) error {
+	deleted, err := s.RedisClient.Del(ctx, s.key(apiKeyRedisKey(id))).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrApiKeyNotFound
+	}
 	return nil
 }
+
+// AddUrlMappingVersion numbers the version after the last one kept. The
+// history expires together with the mapping.
+func (s *StorageService) AddUrlMappingVersion(ctx context.Context, shortUrl string, version UrlMappingVersion) (*UrlMappingVersion, error) {
+	key := s.key(historyRedisKey(shortUrl))
+
+	ttl, err := s.RedisClient.PTTL(ctx, s.key(shortUrl)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	add := func(tx *redis.Tx) error {
+		last, err := tx.LIndex(ctx, key, -1).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+
+		version.Version = 1
+		if err == nil {
+			previous, err := decodeUrlMappingVersion(last)
+			if err != nil {
+				return err
+			}
+			version.Version = previous.Version + 1
+		}
+
+		value, err := encodeUrlMappingVersion(version)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.RPush(ctx, key, value)
+			pipe.LTrim(ctx, key, -MaxUrlMappingVersions, -1)
+			if ttl > 0 {
+				pipe.PExpire(ctx, key, ttl)
+			} else {
+				pipe.Persist(ctx, key)
+			}
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < maxHistoryAttempts; attempt++ {
+		err = s.RedisClient.Watch(ctx, add, key)
+		if err != redis.TxFailedErr {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+func (s *StorageService) ListUrlMappingVersions(ctx context.Context, shortUrl string) ([]UrlMappingVersion, error) {
+	values, err := s.RedisClient.LRange(ctx, s.key(historyRedisKey(shortUrl)), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]UrlMappingVersion, len(values))
+	for i, value := range values {
+		version, err := decodeUrlMappingVersion(value)
+		if err != nil {
+			return nil, err
+		}
+		versions[len(values)-1-i] = *version
+	}
+	return versions, nil
+}
@@ -46,7 +46,7 @@ func TestSaveUrlMappingSuccess(t *testing.T) {
 	initialUrl := "https://www.guru3d.com/news-story/spotted-ryzen-threadripper-pro-3995wx-processor-with-8-channel-ddr4,2.html"
 	shortUrl := "Jsz4k57oAX"
 
-	err := storageService.SaveUrlMapping(ctx, shortUrl, initialUrl)
+	err := storageService.SaveUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: initialUrl})
 	assert.NoError(t, err)
 }
 
@@ -65,10 +65,206 @@ func TestSaveUrlMappingFail(t *testing.T) {
 	shortUrl := "Jsz4k57oAX"
 
 	redisServer.SetError("REDISDOWN")
-	err := storageService.SaveUrlMapping(ctx, shortUrl, initialUrl)
+	err := storageService.SaveUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: initialUrl})
 	assert.Error(t, err)
 }
 
+func TestSaveUrlMappingWithExpirySetsKeyTtl(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+
+	initialUrl := "https://www.guru3d.com/news-story/spotted-ryzen-threadripper-pro-3995wx-processor-with-8-channel-ddr4,2.html"
+	shortUrl := "Jsz4k57oAX"
+	expiresAt := time.Now().Add(time.Hour)
+
+	err := storageService.SaveUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: initialUrl, ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+
+	ttl := redisServer.TTL(shortUrl)
+	assert.Greater(t, ttl, time.Hour)
+	assert.LessOrEqual(t, ttl, time.Hour+store.ExpiredUrlRetention)
+
+	mapping, err := storageService.RetrieveUrlMapping(ctx, shortUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, initialUrl, mapping.OriginalUrl)
+	assert.True(t, expiresAt.Equal(*mapping.ExpiresAt))
+}
+
+func TestSaveRemovedUrlMappingClearsKeyTtl(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+
+	shortUrl := "Jsz4k57oAX"
+	expiresAt := time.Now().Add(time.Hour)
+	err := storageService.SaveUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: "https://example.com", ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+
+	deletedAt := time.Now()
+	err = storageService.SaveUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: "https://example.com", ExpiresAt: &expiresAt, DeletedAt: &deletedAt})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), redisServer.TTL(shortUrl))
+
+	err = storageService.SaveUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: "https://example.com", ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+	assert.Greater(t, redisServer.TTL(shortUrl), time.Hour)
+}
+
+func TestRetrieveInitialUrlExpired(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+
+	initialUrl := "https://www.guru3d.com/news-story/spotted-ryzen-threadripper-pro-3995wx-processor-with-8-channel-ddr4,2.html"
+	shortUrl := "Jsz4k57oAX"
+	expiresAt := time.Now().Add(-time.Minute)
+
+	err := storageService.SaveUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: initialUrl, ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+
+	retrievedUrl, err := storageService.RetrieveInitialUrl(ctx, shortUrl)
+	assert.Empty(t, retrievedUrl)
+	assert.ErrorIs(t, err, store.ErrUrlExpired)
+}
+
+func TestCreateUrlMappingSuccess(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+
+	initialUrl := "https://www.guru3d.com/news-story/spotted-ryzen-threadripper-pro-3995wx-processor-with-8-channel-ddr4,2.html"
+	shortUrl := "Jsz4k57oAX"
+
+	err := storageService.CreateUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: initialUrl})
+	assert.NoError(t, err)
+
+	retrievedUrl, err := storageService.RetrieveInitialUrl(ctx, shortUrl)
+	assert.Equal(t, initialUrl, retrievedUrl)
+	assert.NoError(t, err)
+}
+
+func TestCreateUrlMappingTaken(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+
+	initialUrl := "https://www.guru3d.com/news-story/spotted-ryzen-threadripper-pro-3995wx-processor-with-8-channel-ddr4,2.html"
+	shortUrl := "Jsz4k57oAX"
+
+	redisClient.Set(ctx, shortUrl, initialUrl, CacheDuration)
+
+	err := storageService.CreateUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: "https://youtu.be/8LhMu4bQTQU"})
+	assert.ErrorIs(t, err, store.ErrShortUrlTaken)
+
+	retrievedUrl, err := storageService.RetrieveInitialUrl(ctx, shortUrl)
+	assert.Equal(t, initialUrl, retrievedUrl)
+	assert.NoError(t, err)
+}
+
+func TestCreateUrlMappingReplacesExpired(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+
+	shortUrl := "Jsz4k57oAX"
+	expiresAt := time.Now().Add(-time.Minute)
+	err := storageService.SaveUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: "https://youtu.be/8LhMu4bQTQU", ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+
+	initialUrl := "https://www.guru3d.com/news-story/spotted-ryzen-threadripper-pro-3995wx-processor-with-8-channel-ddr4,2.html"
+	err = storageService.CreateUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: initialUrl})
+	assert.NoError(t, err)
+
+	retrievedUrl, err := storageService.RetrieveInitialUrl(ctx, shortUrl)
+	assert.Equal(t, initialUrl, retrievedUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), redisServer.TTL(shortUrl))
+}
+
+func TestCreateUrlMappingReplacingExpiredClearsHistoryAndIndexes(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+
+	shortUrl := "Jsz4k57oAX"
+	expiresAt := time.Now().Add(-time.Minute)
+	err := storageService.SaveUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: "https://youtu.be/8LhMu4bQTQU", Owner: "previous", ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+	_, err = storageService.AddUrlMappingVersion(ctx, shortUrl, store.UrlMappingVersion{NewUrl: "https://youtu.be/8LhMu4bQTQU", ChangedAt: time.Now()})
+	assert.NoError(t, err)
+
+	now := time.Now()
+	err = storageService.CreateUrlMapping(ctx, shortUrl, store.UrlMapping{OriginalUrl: "https://example.com", Owner: "owner", CreatedAt: &now})
+	assert.NoError(t, err)
+
+	versions, err := storageService.ListUrlMappingVersions(ctx, shortUrl)
+	assert.NoError(t, err)
+	assert.Empty(t, versions)
+	page, err := storageService.ListUrlMappings(ctx, "owner", store.ListQuery{SortBy: store.SortByCreatedAt})
+	assert.NoError(t, err)
+	assert.Len(t, page.Mappings, 1)
+}
+
+func TestCreateUrlMappingFail(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+
+	redisServer.SetError("REDISDOWN")
+	err := storageService.CreateUrlMapping(ctx, "Jsz4k57oAX", store.UrlMapping{OriginalUrl: "https://youtu.be/8LhMu4bQTQU"})
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, store.ErrShortUrlTaken)
+}
+
 func TestRetrieveInitialUrlSuccess(t *testing.T) {
 	redisServer := miniredis.RunT(t)
 	redisClient := redis.NewClient(&redis.Options{
@@ -194,3 +390,63 @@ func TestDeleteUrlMappingRedisFail(t *testing.T) {
 	err := storageService.DeleteUrlMapping(ctx, "Jsz4k57oAX")
 	assert.Error(t, err)
 }
+
+func TestReservedKeysAreNotUrlMappings(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+	}
+	assert.NoError(t, storageService.SaveApiKey(ctx, store.ApiKey{Id: "key", Hash: "hash", Principal: "owner"}))
+
+	_, err := storageService.RetrieveUrlMapping(ctx, "apikey:key")
+	assert.ErrorIs(t, err, store.ErrUrlNotFound)
+	assert.False(t, storageService.CheckIfShortUrlExists(ctx, "apikey:key"))
+
+	err = storageService.CreateUrlMapping(ctx, "deleted:links", store.UrlMapping{OriginalUrl: "https://www.youtube.com/watch?v=dQw4w9WgXcQ"})
+	assert.ErrorIs(t, err, store.ErrReservedKey)
+	err = storageService.SaveUrlMapping(ctx, "apikey:key", store.UrlMapping{OriginalUrl: "https://www.youtube.com/watch?v=dQw4w9WgXcQ"})
+	assert.ErrorIs(t, err, store.ErrReservedKey)
+	assert.NoError(t, storageService.DeleteUrlMapping(ctx, "apikey:key"))
+
+	apiKey, err := storageService.RetrieveApiKey(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "owner", apiKey.Principal)
+}
+
+func TestDeleteAndPurgeDropClickStats(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+	ctx := context.TODO()
+
+	storageService := store.StorageService{
+		RedisClient: redisClient,
+		KeyPrefix:   "henshin:",
+	}
+
+	now := time.Now()
+	deletedAt := now.Add(-time.Hour)
+	for _, shortUrl := range []string{"cosmos", "dyna"} {
+		assert.NoError(t, storageService.CreateUrlMapping(ctx, shortUrl, store.UrlMapping{
+			OriginalUrl: "https://ultra.fandom.com/wiki/Ultraman",
+			DeletedAt:   &deletedAt,
+		}))
+		redisClient.Incr(ctx, "henshin:"+store.StatsKey(shortUrl, "clicks"))
+		redisClient.HIncrBy(ctx, "henshin:"+store.HourlyStatsKey(shortUrl, now.AddDate(0, 0, -7)), "13", 1)
+		redisClient.HIncrBy(ctx, "henshin:"+store.DailyStatsKey(shortUrl, now.AddDate(-1, 0, 0)), "2021-11-09", 1)
+		redisClient.HIncrBy(ctx, "henshin:"+store.LegacyDailyStatsKey(shortUrl), "2020-11-09", 1)
+	}
+
+	assert.NoError(t, storageService.DeleteUrlMapping(ctx, "cosmos"))
+	purged, err := storageService.PurgeDeletedUrlMappings(ctx, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dyna"}, purged)
+
+	assert.Empty(t, redisServer.Keys())
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ExpiredUrlRetention is how long an expired mapping is kept around so the
+// redirect can still tell an expired link apart from one that never existed.
+const ExpiredUrlRetention = 30 * 24 * time.Hour
+
+// DefaultDeletedUrlRetention is how long a removed mapping can be restored
+// before the reaper purges it.
+const DefaultDeletedUrlRetention = 30 * 24 * time.Hour
+
+// Values of UrlMapping.QueryPrecedence, deciding what happens to a query
+// parameter that is both in the incoming request and in the original url.
+// An empty precedence means QueryPrecedenceIncoming.
+const (
+	QueryPrecedenceIncoming    = "incoming"
+	QueryPrecedenceDestination = "destination"
+	QueryPrecedenceAppend      = "append"
+)
+
+// reservedKeyPrefixes are the prefixes of the Redis keys that aren't url
+// mappings: api keys, owner indexes, edit histories, the index of removed
+// mappings, click statistics, rate limits, keyspace migrations and the audit
+// trail.
+var reservedKeyPrefixes = []string{apiKeyPrefix, ownerIndexPrefix, historyPrefix, deletedIndexPrefix, statsPrefix, "ratelimit:", "migration:", "audit:"}
+
+// reservedRouteNames are the first path segments of the routes of the
+// service, a short url named after one of them would never be redirected.
+var reservedRouteNames = []string{"admin", "bulk-create-short-url", "create-short-url", "links", "remove-url", "stats", "update-url", "users"}
+
+var (
+	ErrUrlNotFound   = errors.New("url mapping not found")
+	ErrUrlExpired    = errors.New("url mapping has expired")
+	ErrUrlDeleted    = errors.New("url mapping has been removed")
+	ErrShortUrlTaken = errors.New("short url is already taken")
+	ErrReservedKey   = errors.New("short url is a reserved key")
+)
+
+type UrlMapping struct {
+	OriginalUrl  string     `json:"original_url"`
+	Owner        string     `json:"owner,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	RedirectType int        `json:"redirect_type,omitempty"`
+
+	ForwardQuery    bool   `json:"forward_query,omitempty"`
+	ForwardPath     bool   `json:"forward_path,omitempty"`
+	QueryPrecedence string `json:"query_precedence,omitempty"`
+
+	Title string   `json:"title,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+
+	// DeletedAt is set while a removed mapping can still be restored.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// NewUrlMapping is one mapping of a CreateUrlMappings batch.
+type NewUrlMapping struct {
+	ShortUrl string
+	Mapping  UrlMapping
+}
+
+// createUrlMappingsOneByOne is CreateUrlMappings for the storages that gain
+// nothing from batching.
+func createUrlMappingsOneByOne(ctx context.Context, storage StorageServiceI, mappings []NewUrlMapping) []error {
+	errs := make([]error, len(mappings))
+	for i, mapping := range mappings {
+		errs[i] = storage.CreateUrlMapping(ctx, mapping.ShortUrl, mapping.Mapping)
+	}
+	return errs
+}
+
+// IsOwnedBy is false for mappings written before owners were recorded, so
+// those can't be changed by anyone through the api.
+func (m *UrlMapping) IsOwnedBy(userId string) bool {
+	return m.Owner != "" && m.Owner == userId
+}
+
+// IsUrlMappingKey tells the Redis keys holding url mappings apart from the
+// other keys the service writes.
+func IsUrlMappingKey(key string) bool {
+	for _, prefix := range reservedKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsValidShortUrl tells whether a new short url can be created: it can't be
+// one of the service's own keys or routes, and "/", "{" and "}" are kept out
+// as they break the route and Redis cluster hash tags.
+func IsValidShortUrl(shortUrl string) bool {
+	return shortUrl != "" && IsUrlMappingKey(shortUrl) && !IsReservedRouteName(shortUrl) && !strings.ContainsAny(shortUrl, "/{}")
+}
+
+func IsReservedRouteName(shortUrl string) bool {
+	for _, name := range reservedRouteNames {
+		if shortUrl == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isGone tells whether an expired mapping is past ExpiredUrlRetention and is
+// treated as never existing. Removed mappings stay until they are purged.
+func (m *UrlMapping) isGone(now time.Time) bool {
+	return !m.IsDeleted() && m.ExpiresAt != nil && now.After(m.ExpiresAt.Add(ExpiredUrlRetention))
+}
+
+func (m *UrlMapping) IsExpired(now time.Time) bool {
+	return m.ExpiresAt != nil && !now.Before(*m.ExpiresAt)
+}
+
+func (m *UrlMapping) IsDeleted() bool {
+	return m.DeletedAt != nil
+}
+
+func encodeUrlMapping(mapping UrlMapping) (string, error) {
+	encoded, err := json.Marshal(mapping)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// decodeUrlMapping also accepts the plain original url values written before
+// mappings were stored as JSON records.
+func decodeUrlMapping(value string) (*UrlMapping, error) {
+	if !strings.HasPrefix(value, "{") {
+		return &UrlMapping{OriginalUrl: value}, nil
+	}
+
+	var mapping UrlMapping
+	err := json.Unmarshal([]byte(value), &mapping)
+	if err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
+
+// mappingExpiration is the TTL of the Redis key of mapping. A removed mapping
+// has none, it is kept until the reaper purges it once it can't be restored
+// anymore.
+func mappingExpiration(mapping UrlMapping) time.Duration {
+	if mapping.ExpiresAt == nil || mapping.IsDeleted() {
+		return 0
+	}
+	expiration := time.Until(*mapping.ExpiresAt) + ExpiredUrlRetention
+	if expiration < time.Second {
+		return time.Second
+	}
+	return expiration
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	historyPrefix = "history:"
+
+	// MaxUrlMappingVersions is how many versions are kept per short url, the
+	// oldest ones are dropped first.
+	MaxUrlMappingVersions = 100
+
+	maxHistoryAttempts = 3
+)
+
+// UrlMappingVersion is one change of the original url of a mapping. The
+// first version of a mapping has no OldUrl. Versions are numbered from 1 and
+// start over when a short url is created again after being removed or
+// expiring. ListUrlMappingVersions returns the newest version first.
+type UrlMappingVersion struct {
+	Version      int64     `json:"version"`
+	OldUrl       string    `json:"old_url,omitempty"`
+	NewUrl       string    `json:"new_url"`
+	ChangedBy    string    `json:"changed_by,omitempty"`
+	ChangedAt    time.Time `json:"changed_at"`
+	RolledBackTo int64     `json:"rolled_back_to,omitempty"`
+}
+
+func historyRedisKey(shortUrl string) string {
+	return historyPrefix + shortUrl
+}
+
+func encodeUrlMappingVersion(version UrlMappingVersion) (string, error) {
+	encoded, err := json.Marshal(version)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+func decodeUrlMappingVersion(value string) (*UrlMappingVersion, error) {
+	var version UrlMappingVersion
+	err := json.Unmarshal([]byte(value), &version)
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
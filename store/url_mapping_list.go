@@ -0,0 +1,123 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Fields a listing can be sorted by.
+const (
+	SortByCreatedAt = "created_at"
+	SortByUpdatedAt = "updated_at"
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListQuery selects a page of the mappings of one owner. Contains filters on a
+// case-insensitive substring of the original url. Cursor is the NextCursor of
+// the previous page, empty for the first one. Deleted lists the removed
+// mappings that can still be restored instead of the live ones.
+type ListQuery struct {
+	SortBy    string
+	Ascending bool
+	Contains  string
+	Deleted   bool
+	Cursor    string
+	Limit     int
+}
+
+type ListedUrlMapping struct {
+	ShortUrl string
+	UrlMapping
+}
+
+// UrlMappingPage has an empty NextCursor on the last page. A page can hold
+// fewer than Limit mappings and still not be the last one.
+type UrlMappingPage struct {
+	Mappings   []ListedUrlMapping
+	NextCursor string
+}
+
+// listCursor is the position of the last mapping of a page: its sort key and,
+// to break ties, its short url.
+type listCursor struct {
+	SortKey  int64  `json:"k"`
+	ShortUrl string `json:"u"`
+}
+
+func (q ListQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultListLimit
+	}
+	if q.Limit > MaxListLimit {
+		return MaxListLimit
+	}
+	return q.Limit
+}
+
+// sortKey is the sort field in unix milliseconds. Mappings from before the
+// timestamps were recorded sort as the oldest.
+func (q ListQuery) sortKey(mapping UrlMapping) int64 {
+	t := mapping.CreatedAt
+	if q.SortBy == SortByUpdatedAt {
+		t = mapping.UpdatedAt
+	}
+	if t == nil {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func (q ListQuery) matches(mapping UrlMapping) bool {
+	if mapping.IsDeleted() != q.Deleted {
+		return false
+	}
+	return q.Contains == "" || strings.Contains(strings.ToLower(mapping.OriginalUrl), strings.ToLower(q.Contains))
+}
+
+// after tells whether the mapping comes after the cursor in the order of the
+// listing.
+func (q ListQuery) after(cursor *listCursor, sortKey int64, shortUrl string) bool {
+	if cursor == nil {
+		return true
+	}
+	if sortKey == cursor.SortKey {
+		if q.Ascending {
+			return shortUrl > cursor.ShortUrl
+		}
+		return shortUrl < cursor.ShortUrl
+	}
+	if q.Ascending {
+		return sortKey > cursor.SortKey
+	}
+	return sortKey < cursor.SortKey
+}
+
+func encodeListCursor(sortKey int64, shortUrl string) string {
+	content, _ := json.Marshal(listCursor{SortKey: sortKey, ShortUrl: shortUrl})
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+func decodeListCursor(cursor string) (*listCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	content, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var decoded listCursor
+	if err := json.Unmarshal(content, &decoded); err != nil || decoded.ShortUrl == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &decoded, nil
+}
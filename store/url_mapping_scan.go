@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const scanBatchSize = 500
+
+// ScanUrlMappings SCANs the whole keyspace, every master of a cluster, and
+// reads the mappings found in pipelined batches. Removed and expired mappings
+// still kept are included. A key SCAN returns twice is only passed to fn once,
+// keys written during the scan may or may not be.
+func (s *StorageService) ScanUrlMappings(ctx context.Context, fn func(ListedUrlMapping) error) error {
+	nodes, err := s.scanNodes(ctx)
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	match := escapeScanPattern(s.KeyPrefix) + "*"
+	for _, node := range nodes {
+		var cursor uint64
+		for {
+			var keys []string
+			keys, cursor, err = node.ScanType(ctx, cursor, match, scanBatchSize, "string").Result()
+			if err != nil {
+				return err
+			}
+
+			var shortUrls []string
+			for _, key := range keys {
+				shortUrl := strings.TrimPrefix(key, s.KeyPrefix)
+				if !IsUrlMappingKey(shortUrl) || seen[shortUrl] {
+					continue
+				}
+				seen[shortUrl] = true
+				shortUrls = append(shortUrls, shortUrl)
+			}
+
+			err = s.scanUrlMappingBatch(ctx, node, shortUrls, fn)
+			if err != nil {
+				return err
+			}
+			if cursor == 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (s *StorageService) scanUrlMappingBatch(ctx context.Context, node redis.Cmdable, shortUrls []string, fn func(ListedUrlMapping) error) error {
+	if len(shortUrls) == 0 {
+		return nil
+	}
+
+	pipe := node.Pipeline()
+	values := make([]*redis.StringCmd, len(shortUrls))
+	for i, shortUrl := range shortUrls {
+		values[i] = pipe.Get(ctx, s.key(shortUrl))
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	for i, shortUrl := range shortUrls {
+		value, err := values[i].Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		mapping, err := decodeUrlMapping(value)
+		if err != nil {
+			return err
+		}
+		err = fn(ListedUrlMapping{ShortUrl: shortUrl, UrlMapping: *mapping})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanNodes gives every master of a cluster, since a SCAN only covers the node
+// it runs on, or the single Redis otherwise.
+func (s *StorageService) scanNodes(ctx context.Context) ([]redis.Cmdable, error) {
+	clusterClient, ok := s.RedisClient.(*redis.ClusterClient)
+	if !ok {
+		return []redis.Cmdable{s.RedisClient}, nil
+	}
+
+	var mu sync.Mutex
+	var masters []*redis.Client
+	err := clusterClient.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		mu.Lock()
+		defer mu.Unlock()
+		masters = append(masters, client)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(masters, func(i, j int) bool {
+		return masters[i].Options().Addr < masters[j].Options().Addr
+	})
+	nodes := make([]redis.Cmdable, len(masters))
+	for i, master := range masters {
+		nodes[i] = master
+	}
+	return nodes, nil
+}
+
+func escapeScanPattern(prefix string) string {
+	var escaped strings.Builder
+	for _, r := range prefix {
+		if strings.ContainsRune(`*?[]\`, r) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}